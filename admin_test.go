@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"webtest/solar"
+)
+
+//Copies energy.csv, solar.csv and the tariffs to a new directory and gives
+//a server of that data, with the admin token "secret".
+func testServer(t *testing.T) (*Server, solar.DataFiles) {
+	t.Helper()
+	dir := t.TempDir()
+	files := solar.DataFiles{
+		Cities:     filepath.Join(dir, "energy.csv"),
+		Panels:     filepath.Join(dir, "solar.csv"),
+		Monthly:    filepath.Join(dir, "monthly.csv"),
+		Batteries:  filepath.Join(dir, "batteries.csv"),
+		Incentives: filepath.Join(dir, "incentives.json"),
+		Tariffs:    filepath.Join(dir, "tariffs"),
+	}
+	if err := os.Mkdir(files.Tariffs, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"energy.csv", "solar.csv", "batteries.csv", "incentives.json"} {
+		content, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store, err := solar.NewStore(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Server{Data: store, AdminToken: "secret"}, files
+}
+
+//Sends the request to handler and gives the response.
+func serve(handler http.HandlerFunc, method, body string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/", strings.NewReader(body))
+	for key, value := range header {
+		r.Header.Set(key, value)
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestReloadAPI(t *testing.T) {
+	server, files := testServer(t)
+	old := server.Data.Dataset()
+	bearer := map[string]string{"Authorization": "Bearer secret"}
+	tests := []struct {
+		name   string
+		method string
+		header map[string]string
+		status int
+		err    string
+	}{
+		{"GET", http.MethodGet, bearer, http.StatusMethodNotAllowed, "method must be POST"},
+		{"no token", http.MethodPost, nil, http.StatusUnauthorized, "missing or wrong admin token"},
+		{"wrong token", http.MethodPost, map[string]string{"Authorization": "Bearer secrets"}, http.StatusUnauthorized, "missing or wrong admin token"},
+		{"token without Bearer", http.MethodPost, map[string]string{"Authorization": "Basic secret"}, http.StatusUnauthorized, "missing or wrong admin token"},
+	}
+	for _, test := range tests {
+		w := serve(server.ReloadAPI, test.method, "", test.header)
+		var body APIError
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil || w.Code != test.status || body.Error != test.err {
+			t.Errorf("%s: got %d %q (%v), want %d %q", test.name, w.Code, body.Error, err, test.status, test.err)
+		}
+	}
+	if w := serve(server.ReloadAPI, http.MethodGet, "", nil); w.Header().Get("Allow") != http.MethodPost {
+		t.Errorf("GET: got Allow %q, want POST", w.Header().Get("Allow"))
+	}
+	//Without ADMIN_TOKEN the endpoint is off, whatever token is sent.
+	closed := &Server{Data: server.Data}
+	if w := serve(closed.ReloadAPI, http.MethodPost, "", map[string]string{"Authorization": "Bearer "}); w.Code != http.StatusUnauthorized {
+		t.Errorf("no ADMIN_TOKEN: got %d, want 401", w.Code)
+	}
+	if server.Data.Dataset() != old {
+		t.Error("a refused request reloaded the data")
+	}
+
+	//A bad panel: 422 and the old data is kept.
+	if err := os.WriteFile(files.Panels, []byte("Kyocera,16,315,2.19,399,-0.46,45,0.8,15\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w := serve(server.ReloadAPI, http.MethodPost, "", bearer)
+	var failed APIError
+	if err := json.NewDecoder(w.Body).Decode(&failed); err != nil || w.Code != http.StatusUnprocessableEntity ||
+		!strings.HasPrefix(failed.Error, "reload failed, keeping the old data: ") || !strings.Contains(failed.Error, "solar.csv:1: column 9") {
+		t.Errorf("a bad panel: got %d %q (%v), want 422", w.Code, failed.Error, err)
+	}
+	if server.Data.Dataset() != old {
+		t.Error("a failed reload replaced the data")
+	}
+
+	if err := os.WriteFile(files.Panels, []byte("Kyocera,16,315,2.19,399\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w = serve(server.ReloadAPI, http.MethodPost, "", bearer)
+	var result ReloadResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil || w.Code != http.StatusOK ||
+		result != (ReloadResult{"reloaded", len(old.Cities), 1}) || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("a good reload: got %d %+v (%v), want 200 with %d cities and 1 panel", w.Code, result, err, len(old.Cities))
+	}
+	if len(server.Data.Dataset().Panels) != 1 {
+		t.Error("the new data wasn't swapped in")
+	}
+}
@@ -0,0 +1,218 @@
+/*Description: This file is the JSON API of the web app. It takes the same
+inputs as the /selected and /displayheatmap forms as JSON and returns the
+page variables as JSON, so programs can use the estimator without parsing
+the html pages.*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+
+	"webtest/solar"
+)
+
+//This is the JSON body of a POST to /api/v1/estimate.
+type EstimateRequest struct {
+	Latitude    *float64            `json:"latitude"`    //Degrees, north is positive (WGS84)
+	Longitude   *float64            `json:"longitude"`   //Degrees, east is positive (WGS84)
+	HouseSize   *float64            `json:"houseSize"`   //House size (square feet)
+	RoofSize    *float64            `json:"roofSize"`    //Roof size (square feet)
+	Mode        string              `json:"mode"`        //"nearest" (default) or "interpolate"
+	Neighbors   *int                `json:"neighbors"`   //Number of cities to blend when interpolating (default 4)
+	Tilt        *float64            `json:"tilt"`        //Roof pitch (degrees from horizontal, default 0 for a flat roof)
+	Azimuth     *float64            `json:"azimuth"`     //Compass direction the roof faces (degrees, default 180 for south)
+	Losses      solar.Losses        `json:"losses"`      //System losses (percentages); the ones left out keep their defaults
+	Panel       string              `json:"panel"`       //Name of the panel in solar.csv (default the first one)
+	Years       *int                `json:"years"`       //Number of years to forecast (default 25)
+	Finance     solar.FinanceInputs `json:"finance"`     //Electricity price and the other money values; the ones left out keep their defaults
+	Tariff      string              `json:"tariff"`      //ID of a tariff in the tariffs directory (default the closest city's utility)
+	InstallDate string              `json:"installDate"` //Day the panels would be installed, YYYY-MM-DD (default today)
+	Usage       *solar.Usage        `json:"usage"`       //Usage of the home, as /api/v1/usage gives it, or its bills alone (default the city average)
+}
+
+//This is the JSON body of a POST to /api/v1/heatmap.
+type HeatMapRequest struct {
+	HouseSize *float64     `json:"houseSize"` //House size (square feet)
+	RoofSize  *float64     `json:"roofSize"`  //Roof size (square feet)
+	Losses    solar.Losses `json:"losses"`    //System losses (percentages); the ones left out keep their defaults
+	Panel     string       `json:"panel"`     //Name of the panel in solar.csv (default the first one)
+}
+
+//This is the JSON body of a POST to /api/v1/simulate.
+type SimulateRequest struct {
+	Latitude  *float64     `json:"latitude"`  //Degrees, north is positive (WGS84)
+	Longitude *float64     `json:"longitude"` //Degrees, east is positive (WGS84)
+	RoofSize  *float64     `json:"roofSize"`  //Roof size (square feet)
+	Tilt      *float64     `json:"tilt"`      //Roof pitch (degrees from horizontal, default 0 for a flat roof)
+	Azimuth   *float64     `json:"azimuth"`   //Compass direction the roof faces (degrees, default 180 for south)
+	Losses    solar.Losses `json:"losses"`    //System losses (percentages); the ones left out keep their defaults
+	Panel     string       `json:"panel"`     //Name of the panel in solar.csv (default the first one)
+}
+
+//This is the JSON body returned by /api/v1/simulate: the city whose
+//weather file was used and the simulated year.
+type SimulateResponse struct {
+	City           string           `json:"city"`           //City whose weather file was simulated
+	Panel          string           `json:"panel"`          //Panel that was simulated
+	CityDistanceKm float64          `json:"cityDistanceKm"` //Distance to that city (km)
+	Losses         []solar.LossItem `json:"losses"`         //System losses used, itemized
+	solar.Simulation
+}
+
+//This is the JSON body returned with every 4xx or 5xx response. Fields has
+//the error message of every invalid input, keyed by its JSON name.
+type APIError struct {
+	Error  string     `json:"error"`
+	Fields FormErrors `json:"fields,omitempty"`
+}
+
+//Gives the solar estimate for one home, like the /selected page.
+func (s *Server) EstimateAPI(w http.ResponseWriter, r *http.Request) {
+	req := EstimateRequest{Losses: solar.DefaultLosses(), Finance: solar.DefaultFinance()} //the values in the body replace these
+	if !DecodeJSON(w, r, &req) {
+		return
+	}
+	input, errs := req.Input()
+	var PageVars PageVariables
+	if len(errs) == 0 {
+		PageVars, errs = s.MakeEstimate(s.Data.Dataset(), input)
+	}
+	if len(errs) > 0 {
+		WriteJSON(w, http.StatusBadRequest, APIError{"invalid input", errs})
+		return
+	}
+	WriteJSON(w, http.StatusOK, PageVars)
+}
+
+//Gives the color of every city for a house and roof size, like the /displayheatmap page.
+func (s *Server) HeatMapAPI(w http.ResponseWriter, r *http.Request) {
+	req := HeatMapRequest{Losses: solar.DefaultLosses()} //the losses in the body replace these
+	if !DecodeJSON(w, r, &req) {
+		return
+	}
+	input, errs := req.Input()
+	var PageVars PageVariables
+	if len(errs) == 0 {
+		PageVars, errs = MakeHeatMap(s.Data.Dataset(), input)
+	}
+	if len(errs) > 0 {
+		WriteJSON(w, http.StatusBadRequest, APIError{"invalid input", errs})
+		return
+	}
+	WriteJSON(w, http.StatusOK, PageVars)
+}
+
+//Simulates a year of hourly output with the weather file of the closest
+//city. Cities without a weather file give a 400 error under "location".
+func (s *Server) SimulateAPI(w http.ResponseWriter, r *http.Request) {
+	req := SimulateRequest{Losses: solar.DefaultLosses()} //the losses in the body replace these
+	if !DecodeJSON(w, r, &req) {
+		return
+	}
+	input, errs := req.Input()
+	if len(errs) > 0 {
+		WriteJSON(w, http.StatusBadRequest, APIError{"invalid input", errs})
+		return
+	}
+	data := s.Data.Dataset()
+	panel := errs.Panel(data, input.Panel)
+	nearest, err := solar.NearestCity(data.Index, input.Latitude, input.Longitude, s.MaxDistanceKm)
+	if err != nil {
+		errs["location"] = fmt.Sprintf("We don't have solar data for this location: %v.", err)
+	} else if data.Cities[nearest.City].WeatherFile == "" {
+		errs["location"] = fmt.Sprintf("We don't have a weather file for %s, the closest city.", nearest.City)
+	}
+	if len(errs) > 0 {
+		WriteJSON(w, http.StatusBadRequest, APIError{"invalid input", errs})
+		return
+	}
+	weather, err := solar.ReadWeather(data.Cities[nearest.City].WeatherFile)
+	if err != nil {
+		log.Print("reading weather file: ", err)
+		WriteJSON(w, http.StatusInternalServerError, APIError{Error: "the weather file of " + nearest.City + " can't be read"})
+		return
+	}
+	system := solar.SystemSpec{
+		Roof:       input.Roof,
+		RoofSize:   input.RoofSize,
+		Efficiency: panel.Efficiency,
+		TempCoeff:  panel.TempCoeff,
+		NOCT:       panel.NOCT,
+		Losses:     input.Losses,
+	}
+	simulation := solar.Simulate(weather, system)
+	simulation.Annual = float64(int(simulation.Annual*100)) / 100
+	simulation.PlaneOfArray = float64(int(simulation.PlaneOfArray*100)) / 100
+	for m := range simulation.Monthly {
+		simulation.Monthly[m] = float64(int(simulation.Monthly[m]*100)) / 100
+	}
+	for h := range simulation.Hourly {
+		simulation.Hourly[h] = float64(int(simulation.Hourly[h]*1000)) / 1000 //to the wh
+	}
+	WriteJSON(w, http.StatusOK, SimulateResponse{
+		City:           nearest.City,
+		Panel:          panel.Name,
+		CityDistanceKm: float64(int(nearest.DistanceKm*10)) / 10,
+		Losses:         input.Losses.Items(),
+		Simulation:     simulation,
+	})
+}
+
+//Reads a Green Button file, or a csv file of bills when the content type
+//is text/csv, the body of a POST, and gives the usage it holds. The usage
+//can be sent back in an estimate request.
+func (s *Server) UsageAPI(w http.ResponseWriter, r *http.Request) {
+	if !RequirePost(w, r) {
+		return
+	}
+	upload := Uploads[0] //the Green Button file
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" {
+		upload = Uploads[1] //the bills
+	}
+	usage, err := upload.Read(http.MaxBytesReader(w, r.Body, MaxUpload))
+	if err != nil {
+		WriteJSON(w, http.StatusBadRequest, APIError{Error: "invalid " + upload.Label + ": " + err.Error()})
+		return
+	}
+	WriteJSON(w, http.StatusOK, usage)
+}
+
+//Reports whether the request is a POST. If it is not, it writes the error
+//response.
+func RequirePost(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		WriteJSON(w, http.StatusMethodNotAllowed, APIError{Error: "method must be POST"})
+		return false
+	}
+	return true
+}
+
+//Decodes the JSON body of a POST request into v. If the request is not a POST
+//or the body is not valid JSON, it writes the error response and returns false.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if !RequirePost(w, r) {
+		return false
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		WriteJSON(w, http.StatusBadRequest, APIError{Error: "invalid JSON body: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+//Writes v as the JSON body of the response with the given status code.
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Print("json encoding error: ", err)
+	}
+}
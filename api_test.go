@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+//A request for a home in Albuquerque.
+const albuquerque = `"latitude": 35.0853, "longitude": -106.6056, "roofSize": 1500`
+
+//Decodes the body of an error response. It must have "error" and may have
+//"fields", nothing else.
+func decodeError(t *testing.T, name string, body []byte) APIError {
+	t.Helper()
+	var keys map[string]json.RawMessage
+	var apiError APIError
+	if err := json.Unmarshal(body, &keys); err != nil {
+		t.Errorf("%s: the body %q is not a JSON object: %v", name, body, err)
+	} else if err := json.Unmarshal(body, &apiError); err != nil {
+		t.Errorf("%s: the body %q is not an APIError: %v", name, body, err)
+	}
+	for key := range keys {
+		if key != "error" && key != "fields" {
+			t.Errorf("%s: the error body has %q", name, key)
+		}
+	}
+	if apiError.Error == "" {
+		t.Errorf("%s: the error body %q has no message", name, body)
+	}
+	return apiError
+}
+
+//These are requests an API endpoint must refuse.
+type badRequest struct {
+	name   string
+	method string
+	body   string
+	status int
+	err    string            //the start of the error message
+	fields map[string]string //the messages of the fields, all of them
+}
+
+//Sends each request to handler and checks the status, the error and the
+//fields of the response.
+func checkBadRequests(t *testing.T, handler http.HandlerFunc, tests []badRequest) {
+	t.Helper()
+	for _, test := range tests {
+		w := serve(handler, test.method, test.body, map[string]string{"Content-Type": "application/json"})
+		if w.Code != test.status || w.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s: got %d with %q, want %d with application/json", test.name, w.Code, w.Header().Get("Content-Type"), test.status)
+		}
+		body := decodeError(t, test.name, w.Body.Bytes())
+		if !strings.HasPrefix(body.Error, test.err) {
+			t.Errorf("%s: got the error %q, want %q", test.name, body.Error, test.err)
+		}
+		if len(body.Fields) != len(test.fields) {
+			t.Errorf("%s: got the fields %v, want %v", test.name, body.Fields, test.fields)
+		}
+		for field, message := range test.fields {
+			if body.Fields[field] != message {
+				t.Errorf("%s: got %q under %s, want %q", test.name, body.Fields[field], field, message)
+			}
+		}
+	}
+}
+
+//These are the requests every JSON endpoint refuses, whatever it does.
+func refusedBodies() []badRequest {
+	return []badRequest{
+		{"GET", http.MethodGet, "", http.StatusMethodNotAllowed, "method must be POST", nil},
+		{"not JSON", http.MethodPost, "latitude=35", http.StatusBadRequest, "invalid JSON body: ", nil},
+		{"unknown field", http.MethodPost, `{"lattitude": 35}`, http.StatusBadRequest, `invalid JSON body: json: unknown field "lattitude"`, nil},
+		{"NaN", http.MethodPost, `{"latitude": NaN}`, http.StatusBadRequest, "invalid JSON body: ", nil},
+		{"a number as a string", http.MethodPost, `{"roofSize": "1500"}`, http.StatusBadRequest, "invalid JSON body: ", nil},
+		{"over 1MB", http.MethodPost, `{"panel": "` + strings.Repeat("a", 1<<20) + `"}`, http.StatusBadRequest, "invalid JSON body: http: request body too large", nil},
+	}
+}
+
+func TestEstimateAPI(t *testing.T) {
+	server, _ := testServer(t)
+	w := serve(server.EstimateAPI, http.MethodPost, `{`+albuquerque+`, "houseSize": 2000}`, nil)
+	var estimate map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&estimate); err != nil || w.Code != http.StatusOK || estimate["city"] != "Albuquerque" {
+		t.Errorf("a good request: got %d, %v and the city %v; want 200 and Albuquerque", w.Code, err, estimate["city"])
+	}
+
+	tests := append(refusedBodies(),
+		badRequest{"nothing", http.MethodPost, `{}`, http.StatusBadRequest, "invalid input", map[string]string{
+			"latitude":  "The latitude is required.",
+			"longitude": "The longitude is required.",
+			"houseSize": "The house size is required.",
+			"roofSize":  "The roof size is required.",
+		}},
+		badRequest{"out of range", http.MethodPost,
+			`{"latitude": 91, "longitude": -181, "houseSize": 0, "roofSize": 100001, "mode": "closest", "neighbors": 11, "tilt": 95, "azimuth": -1,
+			"losses": {"soiling": 100, "inverterEfficiency": 40}, "years": 0, "finance": {"price": -0.1}, "installDate": "12/31/2024"}`,
+			http.StatusBadRequest, "invalid input", map[string]string{
+				"latitude":                  "The latitude must be between -90 and 90.",
+				"longitude":                 "The longitude must be between -180 and 180.",
+				"houseSize":                 "The house size must be greater than 0 and at most 100000 square feet.",
+				"roofSize":                  "The roof size must be greater than 0 and at most 100000 square feet.",
+				"mode":                      `The mode must be "nearest" or "interpolate".`,
+				"neighbors":                 "The number of cities must be between 1 and 10.",
+				"tilt":                      "The roof pitch must be between 0 and 90.",
+				"azimuth":                   "The roof direction must be between 0 and 360.",
+				"losses.soiling":            "The soiling loss must be between 0 and 99.",
+				"losses.inverterEfficiency": "The inverter efficiency must be between 50 and 100.",
+				"years":                     "The number of years must be between 1 and 40.",
+				"finance.price":             "The electricity price must be between 0 and 10.",
+				"installDate":               "The installation date must be written as YYYY-MM-DD.",
+			}},
+		badRequest{"unknown panel", http.MethodPost, `{` + albuquerque + `, "houseSize": 2000, "panel": "Solarex"}`,
+			http.StatusBadRequest, "invalid input", map[string]string{"panel": `We don't have a panel named "Solarex".`}},
+	)
+	checkBadRequests(t, server.EstimateAPI, tests)
+}
+
+func TestSimulateAPI(t *testing.T) {
+	server, _ := testServer(t)
+	tests := append(refusedBodies(),
+		badRequest{"nothing", http.MethodPost, `{}`, http.StatusBadRequest, "invalid input", map[string]string{
+			"latitude":  "The latitude is required.",
+			"longitude": "The longitude is required.",
+			"roofSize":  "The roof size is required.",
+		}},
+		badRequest{"out of range", http.MethodPost, `{"latitude": -91, "longitude": 180.5, "roofSize": -1, "tilt": -5, "azimuth": 361, "losses": {"shading": -1}}`,
+			http.StatusBadRequest, "invalid input", map[string]string{
+				"latitude":       "The latitude must be between -90 and 90.",
+				"longitude":      "The longitude must be between -180 and 180.",
+				"roofSize":       "The roof size must be greater than 0 and at most 100000 square feet.",
+				"tilt":           "The roof pitch must be between 0 and 90.",
+				"azimuth":        "The roof direction must be between 0 and 360.",
+				"losses.shading": "The shading loss must be between 0 and 99.",
+			}},
+		//energy.csv has no weather files.
+		badRequest{"no weather file", http.MethodPost, `{` + albuquerque + `}`, http.StatusBadRequest, "invalid input",
+			map[string]string{"location": "We don't have a weather file for Albuquerque, the closest city."}},
+	)
+	checkBadRequests(t, server.SimulateAPI, tests)
+}
@@ -0,0 +1,219 @@
+/*Description: This file holds the Battery struct, the functions that read
+the home battery data (batteries.csv), and a simulation of how a battery
+charges from the panels and powers the home hour by hour over a year.*/
+
+package solar
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+//Years a battery is assumed to last when batteries.csv doesn't say.
+const DefaultBatteryYears = 10
+
+/* This is a battery struct which stores the information for each home
+battery. Every row of batteries.csv is one battery the user can compare,
+with its usable capacity, power, round trip efficiency, installed price and
+optionally its warranty.*/
+type Battery struct {
+	Name       string  //Brand name, the first column of batteries.csv
+	Capacity   float64 //Usable energy (kwh)
+	Power      float64 //Most it charges or discharges in an hour (kw)
+	Efficiency float64 //Round trip efficiency (percentage)
+	Price      float64 //Installed price (dollars)
+	Years      float64 //Warranty (years)
+}
+
+//Makes a slice of all of the Battery objects in the order of the file. A
+//missing file has no batteries.
+func MakeBatteryList(filename string) ([]Battery, error) {
+	if filename == "" {
+		return nil, nil
+	}
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil, nil
+	}
+	lines, err := ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	batteries := make([]Battery, 0)
+	names := make(map[string]bool)
+	for i := 0; i < len(lines); i++ {
+		var items []string = strings.Split(lines[i], ",")
+		if BlankLine(items) {
+			continue
+		}
+		battery, err := MakeBattery(items)
+		if err != nil {
+			return nil, lineError(err, filename, i+1)
+		}
+		if names[battery.Name] {
+			return nil, lineError(fmt.Errorf("battery %q is listed twice", battery.Name), filename, i+1)
+		}
+		names[battery.Name] = true
+		batteries = append(batteries, battery)
+	}
+	return batteries, nil
+}
+
+//Make a Battery object using Battery struct. The numbers must be positive
+//and the efficiency at most 100. The warranty column may be left out; it
+//then gets DefaultBatteryYears.
+func MakeBattery(items []string) (Battery, error) {
+	var battery Battery
+	var err error
+	if len(items) < 5 {
+		return battery, fmt.Errorf("expected at least 5 columns, found %d", len(items))
+	}
+	battery.Name = strings.TrimSpace(items[0])
+	if battery.Name == "" {
+		return battery, &ParseError{Column: 1, Value: items[0], Err: errors.New("missing battery name")}
+	}
+	battery.Years = DefaultBatteryYears
+	numbers := []*float64{&battery.Capacity, &battery.Power, &battery.Efficiency, &battery.Price, &battery.Years}
+	for i, number := range numbers {
+		col := i + 1
+		if col == 5 && (len(items) <= col || strings.TrimSpace(items[col]) == "") {
+			break //no warranty column
+		}
+		*number, err = ParseColumn(items, col)
+		if err != nil {
+			return battery, err
+		}
+		if *number <= 0 {
+			return battery, &ParseError{Column: col + 1, Value: items[col], Err: errors.New("must be positive")}
+		}
+	}
+	if battery.Efficiency > 100 {
+		return battery, &ParseError{Column: 4, Value: items[3], Err: errors.New("efficiency can't be more than 100")}
+	}
+	return battery, nil
+}
+
+//This is what a year with a battery looks like: the energy taken from and
+//sent to the grid, and how much of the panels' output the home used.
+type Dispatch struct {
+	Imports         float64 `json:"imports"`         //Energy taken from the grid (kwh)
+	Exports         float64 `json:"exports"`         //Energy sent to the grid (kwh)
+	SelfConsumption float64 `json:"selfConsumption"` //Share of the panels' output used in the home, directly or through the battery (percentage)
+	Cycles          float64 `json:"cycles"`          //Full discharges of the battery in the year
+}
+
+//Simulates a year hour by hour. load is the energy the home uses and
+//production the energy its panels make in each hour (kwh). The battery
+//stores what the home doesn't use right away and gives it back when the
+//panels make too little, within its capacity and power; half of the
+//round trip loss is taken when charging and half when discharging. It
+//starts empty. imports and exports are the energy taken from and sent to
+//the grid in each hour.
+func (b Battery) Dispatch(load, production []float64) (imports, exports []float64, result Dispatch) {
+	imports = make([]float64, len(load))
+	exports = make([]float64, len(load))
+	oneWay := math.Sqrt(b.Efficiency / 100)
+	var stored, made, discharged float64
+	for h := range load {
+		surplus := production[h] - load[h]
+		made += production[h]
+		if surplus > 0 {
+			var charge float64
+			if oneWay > 0 {
+				charge = math.Min(math.Min(surplus, b.Power), (b.Capacity-stored)/oneWay)
+			}
+			stored += charge * oneWay
+			exports[h] = surplus - charge
+		} else {
+			discharge := math.Min(math.Min(-surplus, b.Power), stored*oneWay)
+			if discharge > 0 {
+				stored -= discharge / oneWay
+			}
+			discharged += discharge
+			imports[h] = -surplus - discharge
+		}
+		result.Imports += imports[h]
+		result.Exports += exports[h]
+	}
+	if made > 0 {
+		result.SelfConsumption = float64(int((made-result.Exports)/made*1000)) / 10
+	}
+	if b.Capacity > 0 {
+		result.Cycles = float64(int(discharged/b.Capacity*10)) / 10
+	}
+	result.Imports = float64(int(result.Imports*100)) / 100
+	result.Exports = float64(int(result.Exports*100)) / 100
+	return imports, exports, result
+}
+
+//Gives the hours a full battery can power the home at its average use,
+//during a power cut.
+func (b Battery) BackupHours(load []float64) float64 {
+	var total float64
+	for _, kwh := range load {
+		total += kwh
+	}
+	if total <= 0 {
+		return 0
+	}
+	average := total / float64(len(load)) //kw
+	if average > b.Power {
+		return 0 //the battery can't keep up with the home
+	}
+	return float64(int(b.Capacity*math.Sqrt(b.Efficiency/100)/average*10)) / 10
+}
+
+//This is one battery's option for a home with panels: how it changes the
+//energy taken from the grid and the bills, and whether it pays for itself.
+type BatteryOption struct {
+	Name        string   `json:"name"`
+	Capacity    float64  `json:"capacity"`    //Usable energy (kwh)
+	Power       float64  `json:"power"`       //Most it charges or discharges in an hour (kw)
+	Cost        int      `json:"cost"`        //Added cost of the battery, installed (dollars)
+	Dispatch    Dispatch `json:"dispatch"`    //The year with the battery
+	BackupHours float64  `json:"backupHours"` //Hours a full battery powers the home at its average use
+	Savings     float64  `json:"savings"`     //Bill savings in the first year on top of the panels' (dollars)
+	Payback     *float64 `json:"payback"`     //Years until the added savings pay for the battery, null if not within its warranty
+}
+
+//Compares the batteries for a home with panels making annual kwh a year,
+//with the bills worked out under the BillModel's tariff. It also gives the
+//year with the panels and no battery. The savings rise with the
+//electricity price escalation (percentage a year).
+func CompareBatteries(bills BillModel, annual float64, batteries []Battery, escalation float64) ([]BatteryOption, Dispatch) {
+	production := make([]float64, len(bills.Shape))
+	for h := range production {
+		production[h] = annual * bills.Shape[h]
+	}
+	_, _, panelsOnly := Battery{}.Dispatch(bills.Load, production)
+	withoutBattery := bills.Tariff.Bill(bills.Load, production).Total
+	options := make([]BatteryOption, 0, len(batteries))
+	for _, battery := range batteries {
+		imports, exports, dispatch := battery.Dispatch(bills.Load, production)
+		savings := withoutBattery - bills.Tariff.Bill(imports, exports).Total
+		option := BatteryOption{
+			Name:        battery.Name,
+			Capacity:    battery.Capacity,
+			Power:       battery.Power,
+			Cost:        int(battery.Price),
+			Dispatch:    dispatch,
+			BackupHours: battery.BackupHours(bills.Load),
+			Savings:     float64(int(savings*100)) / 100,
+		}
+		var cumulative float64
+		for year := 0; float64(year) < battery.Years && savings > 0; year++ {
+			yearly := savings * math.Pow(1+escalation/100, float64(year))
+			if cumulative+yearly >= battery.Price {
+				payback := float64(year) + (battery.Price-cumulative)/yearly
+				payback = float64(int(payback*10)) / 10
+				option.Payback = &payback
+				break
+			}
+			cumulative += yearly
+		}
+		options = append(options, option)
+	}
+	return options, panelsOnly
+}
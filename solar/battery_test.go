@@ -0,0 +1,113 @@
+package solar
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestMakeBattery(t *testing.T) {
+	battery, err := MakeBattery(strings.Split("Home,13.5,5,90,12000", ","))
+	if err != nil || battery.Years != DefaultBatteryYears || battery.Capacity != 13.5 {
+		t.Errorf("no warranty column: got %+v, %v", battery, err)
+	}
+	tests := []struct {
+		name, row, err string
+	}{
+		{"too few columns", "Home,13.5,5,90", "at least 5 columns"},
+		{"no name", " ,13.5,5,90,12000", "missing battery name"},
+		{"not a number", "Home,big,5,90,12000", "column 2"},
+		{"no power", "Home,13.5,0,90,12000", "must be positive"},
+		{"efficiency", "Home,13.5,5,110,12000", "more than 100"},
+		{"warranty", "Home,13.5,5,90,12000,-1", "column 6"},
+	}
+	for _, test := range tests {
+		_, err := MakeBattery(strings.Split(test.row, ","))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got %v, want an error with %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestBatteryDispatch(t *testing.T) {
+	tests := []struct {
+		name             string
+		battery          Battery
+		load, production []float64
+		imports, exports []float64
+		selfConsumption  float64
+		cycles           float64
+	}{
+		{"no battery", Battery{}, []float64{1, 1, 1}, []float64{3, 0, 0},
+			[]float64{0, 1, 1}, []float64{2, 0, 0}, 33.3, 0},
+		//0.9 of the way in and 0.9 out: 4 kwh stored as 3.6 give back 3.24.
+		{"round trip loss", Battery{Capacity: 10, Power: 5, Efficiency: 81}, []float64{0, 2, 2}, []float64{4, 0, 0},
+			[]float64{0, 0, 0.76}, []float64{0, 0, 0}, 100, 0.3},
+		{"power limit", Battery{Capacity: 10, Power: 2, Efficiency: 100}, []float64{0, 4}, []float64{8, 0},
+			[]float64{0, 2}, []float64{6, 0}, 25, 0.2},
+		{"capacity limit", Battery{Capacity: 3, Power: 10, Efficiency: 100}, []float64{0, 5}, []float64{5, 0},
+			[]float64{0, 2}, []float64{2, 0}, 60, 1},
+		{"starts empty", Battery{Capacity: 10, Power: 5, Efficiency: 90}, []float64{2, 1}, []float64{0, 1},
+			[]float64{2, 0}, []float64{0, 0}, 100, 0},
+	}
+	for _, test := range tests {
+		imports, exports, result := test.battery.Dispatch(test.load, test.production)
+		var totalImports, totalExports float64
+		for h := range test.load {
+			if math.Abs(imports[h]-test.imports[h]) > 1e-9 || math.Abs(exports[h]-test.exports[h]) > 1e-9 {
+				t.Errorf("%s: hour %d imports %g and exports %g, want %g and %g",
+					test.name, h, imports[h], exports[h], test.imports[h], test.exports[h])
+			}
+			totalImports += test.imports[h]
+			totalExports += test.exports[h]
+		}
+		if math.Abs(result.Imports-totalImports) > 0.011 || math.Abs(result.Exports-totalExports) > 0.011 {
+			t.Errorf("%s: got %g imported and %g exported in all, want %g and %g",
+				test.name, result.Imports, result.Exports, totalImports, totalExports)
+		}
+		if result.SelfConsumption != test.selfConsumption || result.Cycles != test.cycles {
+			t.Errorf("%s: got %g%% used at home and %g cycles, want %g%% and %g",
+				test.name, result.SelfConsumption, result.Cycles, test.selfConsumption, test.cycles)
+		}
+	}
+}
+
+func TestBackupHours(t *testing.T) {
+	load := []float64{0.5, 1.5, 1, 1} //1 kw on average
+	if got := (Battery{Capacity: 10, Power: 5, Efficiency: 81}).BackupHours(load); got != 9 {
+		t.Errorf("got %g hours, want 9", got)
+	}
+	if got := (Battery{Capacity: 10, Power: 0.5, Efficiency: 81}).BackupHours(load); got != 0 {
+		t.Errorf("a battery weaker than the home: got %g hours, want 0", got)
+	}
+}
+
+func TestCompareBatteries(t *testing.T) {
+	//10 kwh made at noon every day, 9 more than the home uses then, and
+	//none of it is paid for when sent to the grid.
+	load, shape := make([]float64, HoursPerYear), make([]float64, HoursPerYear)
+	for h := range load {
+		load[h] = 1
+		if h%24 == 12 {
+			shape[h] = 1.0 / 365
+		}
+	}
+	bills := BillModel{Tariff: Tariff{Name: "no export", Rate: 0.2, Export: NoExport}, Load: load, Shape: shape}
+	batteries := []Battery{
+		{Name: "Small", Capacity: 5, Power: 5, Efficiency: 100, Price: 1000, Years: 10},
+		{Name: "Dear", Capacity: 5, Power: 5, Efficiency: 100, Price: 10000, Years: 10},
+	}
+	options, panelsOnly := CompareBatteries(bills, 3650, batteries, 0)
+	if panelsOnly.Exports != 3285 {
+		t.Errorf("without a battery %g kwh is sent to the grid, want 3285", panelsOnly.Exports)
+	}
+	//5 kwh a day used in the evening instead of bought: 365 dollars a year.
+	small := options[0]
+	if small.Savings != 365 || small.Dispatch.Exports != 1460 || small.Payback == nil || *small.Payback != 2.7 {
+		t.Errorf("Small: got savings %g, exports %g and payback %v; want 365, 1460 and 2.7",
+			small.Savings, small.Dispatch.Exports, small.Payback)
+	}
+	if options[1].Payback != nil {
+		t.Errorf("Dear paid back in %g years, after its 10 year warranty", *options[1].Payback)
+	}
+}
@@ -0,0 +1,212 @@
+/*Description: This file holds the City struct and the functions that read
+the city data (energy.csv) into a map of City objects. MakeCity(),
+MakeCityMap() and ReadFile() were written by Caryn Willis.*/
+
+package solar
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/*This is a city struct which stores all of the data for each city.
+It stores the name, coordinates, temperature, solar radiation (at flat angle),
+optimal angle, optimal radiation (at optimal angle), average energy usage,
+installation cost, a slice of 3 company names for each city and optionally
+the path of a weather file and the city's state.*/
+type City struct {
+	Name      string   //City name, the first column of energy.csv
+	CoordN    float64  //Degrees north
+	CoordW    float64  //Degrees west
+	Temp      float64  //Average temperature (Fahrenheit)
+	SolarRad  float64  //Solar radiation at a flat angle (kwh/m^2/day)
+	OptAng    float64  //Optimal panel angle (degrees)
+	OptRad    float64  //Solar radiation at the optimal angle (kwh/m^2/day)
+	AvgEnergy float64  //Average monthly energy usage of a home (kwh)
+	InstCost  float64  //Installation cost factor
+	Companies []string //Solar installation companies serving the city
+
+	WeatherFile string //TMY3 or EPW weather file of the city for hourly simulation, empty if none
+	State       string //Two letter state code, such as "AZ", for finding the incentives, empty if not given
+
+	MonthlyRad *[12]float64 //Monthly solar radiation at a flat angle from monthly.csv (kwh/m^2/day), nil if the city isn't listed
+}
+
+//ParseError describes a value in one of the data files that could not be
+//used. Line and Column start at 1, like in a text editor.
+type ParseError struct {
+	File   string
+	Line   int
+	Column int
+	Value  string
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	if e.Column == 0 {
+		return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s:%d: column %d: bad value %q: %v", e.File, e.Line, e.Column, e.Value, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+//Read in the file and return its lines.
+func ReadFile(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", filename, err)
+	}
+	return lines, nil
+}
+
+//Reports whether a line of a csv file has no values, like the empty rows
+//at the end of energy.csv.
+func BlankLine(items []string) bool {
+	for _, item := range items {
+		if strings.TrimSpace(item) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+//Parses the number in column col (starting at 0) of a csv line. The
+//returned *ParseError has no file or line yet; the caller fills them in.
+func ParseColumn(items []string, col int) (float64, error) {
+	value := strings.TrimSpace(items[col])
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, &ParseError{Column: col + 1, Value: items[col], Err: errors.New("not a number")}
+	}
+	if math.IsNaN(number) || math.IsInf(number, 0) {
+		return 0, &ParseError{Column: col + 1, Value: items[col], Err: errors.New("not a finite number")}
+	}
+	return number, nil
+}
+
+//Adds the file name and line number to an error from MakeCity or MakePanel.
+func lineError(err error, filename string, line int) error {
+	if perr, ok := err.(*ParseError); ok {
+		perr.File = filename
+		perr.Line = line
+		return perr
+	}
+	return &ParseError{File: filename, Line: line, Err: err}
+}
+
+//Makes a map data structure of all of the City objects. The empty rows are
+//skipped, and the first bad row stops the loading with an error.
+func MakeCityMap(filename string) (map[string]City, error) {
+	cities, err := MakeCityList(filename)
+	if err != nil {
+		return nil, err
+	}
+	cityData := make(map[string]City)
+	for _, city := range cities {
+		cityData[city.Name] = city
+	}
+	return cityData, nil
+}
+
+//Makes a slice of all of the City objects in the order of the file.
+func MakeCityList(filename string) ([]City, error) {
+	lines, err := ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	cities := make([]City, 0)
+	names := make(map[string]bool)
+	for i := 0; i < len(lines); i++ {
+		var items []string = strings.Split(lines[i], ",")
+		if BlankLine(items) {
+			continue
+		}
+		city, err := MakeCity(items)
+		if err != nil {
+			return nil, lineError(err, filename, i+1)
+		}
+		if names[city.Name] {
+			return nil, lineError(fmt.Errorf("city %q is listed twice", city.Name), filename, i+1)
+		}
+		names[city.Name] = true
+		cities = append(cities, city)
+	}
+	if len(cities) == 0 {
+		return nil, fmt.Errorf("%s: no cities found", filename)
+	}
+	return cities, nil
+}
+
+//Creates a City object with its characteristics using City struct.
+func MakeCity(items []string) (City, error) {
+	var city City
+	var err error
+	if len(items) < 10 {
+		return city, fmt.Errorf("expected at least 10 columns, found %d", len(items))
+	}
+	city.Name = strings.TrimSpace(items[0])
+	if city.Name == "" {
+		return city, &ParseError{Column: 1, Value: items[0], Err: errors.New("missing city name")}
+	}
+	numbers := []*float64{&city.CoordN, &city.CoordW, &city.Temp, &city.SolarRad,
+		&city.OptAng, &city.OptRad, &city.AvgEnergy, &city.InstCost}
+	for i, number := range numbers {
+		*number, err = ParseColumn(items, i+1)
+		if err != nil {
+			return city, err
+		}
+	}
+	if city.SolarRad < 0 {
+		return city, &ParseError{Column: 5, Value: items[4], Err: errors.New("solar radiation can't be negative")}
+	}
+	if city.OptRad < 0 {
+		return city, &ParseError{Column: 7, Value: items[6], Err: errors.New("solar radiation can't be negative")}
+	}
+	if city.AvgEnergy <= 0 {
+		return city, &ParseError{Column: 8, Value: items[7], Err: errors.New("average energy usage must be positive")}
+	}
+	var companyNames []string = strings.Split(items[9], ";")
+	for i := range companyNames {
+		city.Companies = append(city.Companies, strings.TrimSpace(companyNames[i]))
+	}
+	if len(items) > 10 {
+		city.WeatherFile = strings.TrimSpace(items[10]) //optional
+	}
+	if len(items) > 11 {
+		city.State = strings.ToUpper(strings.TrimSpace(items[11])) //optional
+		if city.State != "" && len(city.State) != 2 {
+			return city, &ParseError{Column: 12, Value: items[11], Err: errors.New("the state must be a two letter code")}
+		}
+	}
+	return city, nil
+}
+
+//Make an array of the city names in the order of the file.
+func MakeCityArray(filename string) ([]string, error) {
+	cities, err := MakeCityList(filename)
+	if err != nil {
+		return nil, err
+	}
+	cityArray := make([]string, 0)
+	for _, city := range cities {
+		cityArray = append(cityArray, city.Name)
+	}
+	return cityArray, nil
+}
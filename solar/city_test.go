@@ -0,0 +1,83 @@
+package solar
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+//A good row of energy.csv.
+const albuquerque = "Albuquerque,35.0853,106.6056,57.1,4.14,30.9,4.95,635,4.4,Solar Pro; Sollunasolar,,NM"
+
+//Checks that err is a *ParseError with the file, line, column, value and
+//message given. want.Err is compared by its message.
+func checkParseError(t *testing.T, name string, err error, want ParseError, message string) {
+	t.Helper()
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Errorf("%s: got %v, want a ParseError", name, err)
+		return
+	}
+	if filepath.Base(perr.File) != want.File || perr.Line != want.Line || perr.Column != want.Column || perr.Value != want.Value ||
+		perr.Err == nil || perr.Err.Error() != want.Err.Error() {
+		t.Errorf("%s: got %s line %d column %d value %q: %v; want %s line %d column %d value %q: %v", name,
+			perr.File, perr.Line, perr.Column, perr.Value, perr.Err, want.File, want.Line, want.Column, want.Value, want.Err)
+	}
+	if err.Error() != filepath.Join(filepath.Dir(perr.File), message) {
+		t.Errorf("%s: got the message %q, want %q in the file's directory", name, err.Error(), message)
+	}
+}
+
+func TestMakeCityList(t *testing.T) {
+	cities, err := MakeCityList(writeFile(t, "energy.csv", albuquerque+"\n,,,,\nAnaheim,33.8366,117.9143,67.05,3.99,29.3,4.65,557,3.59,Semper Solaris\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cities) != 2 || cities[0].State != "NM" || len(cities[0].Companies) != 2 || cities[0].Companies[1] != "Sollunasolar" ||
+		cities[1].Name != "Anaheim" || cities[1].State != "" || cities[1].CoordW != 117.9143 {
+		t.Errorf("got %+v", cities)
+	}
+
+	tests := []struct {
+		name, content string
+		want          ParseError
+		message       string
+	}{
+		{"too few columns", "Albuquerque,35.0853,106.6056,57.1,4.14,30.9,4.95,635,4.4\n",
+			ParseError{File: "energy.csv", Line: 1, Err: errors.New("expected at least 10 columns, found 9")},
+			"energy.csv:1: expected at least 10 columns, found 9"},
+		{"not a number", albuquerque + "\nAnaheim,33.8366,117.9143,warm,3.99,29.3,4.65,557,3.59,Semper Solaris\n",
+			ParseError{File: "energy.csv", Line: 2, Column: 4, Value: "warm", Err: errors.New("not a number")},
+			`energy.csv:2: column 4: bad value "warm": not a number`},
+		{"infinite", "Anaheim,33.8366,Inf,67.05,3.99,29.3,4.65,557,3.59,Semper Solaris\n",
+			ParseError{File: "energy.csv", Line: 1, Column: 3, Value: "Inf", Err: errors.New("not a finite number")},
+			`energy.csv:1: column 3: bad value "Inf": not a finite number`},
+		{"negative radiation", "Anaheim,33.8366,117.9143,67.05,-3.99,29.3,4.65,557,3.59,Semper Solaris\n",
+			ParseError{File: "energy.csv", Line: 1, Column: 5, Value: "-3.99", Err: errors.New("solar radiation can't be negative")},
+			`energy.csv:1: column 5: bad value "-3.99": solar radiation can't be negative`},
+		{"no usage", "Anaheim,33.8366,117.9143,67.05,3.99,29.3,4.65,0,3.59,Semper Solaris\n",
+			ParseError{File: "energy.csv", Line: 1, Column: 8, Value: "0", Err: errors.New("average energy usage must be positive")},
+			`energy.csv:1: column 8: bad value "0": average energy usage must be positive`},
+		{"state name", "Anaheim,33.8366,117.9143,67.05,3.99,29.3,4.65,557,3.59,Semper Solaris,,California\n",
+			ParseError{File: "energy.csv", Line: 1, Column: 12, Value: "California", Err: errors.New("the state must be a two letter code")},
+			`energy.csv:1: column 12: bad value "California": the state must be a two letter code`},
+		{"no name", "\n ,33.8366,117.9143,67.05,3.99,29.3,4.65,557,3.59,Semper Solaris\n",
+			ParseError{File: "energy.csv", Line: 2, Column: 1, Value: " ", Err: errors.New("missing city name")},
+			`energy.csv:2: column 1: bad value " ": missing city name`},
+		{"listed twice", albuquerque + "\n" + albuquerque + "\n",
+			ParseError{File: "energy.csv", Line: 2, Err: errors.New(`city "Albuquerque" is listed twice`)},
+			`energy.csv:2: city "Albuquerque" is listed twice`},
+	}
+	for _, test := range tests {
+		_, err := MakeCityList(writeFile(t, "energy.csv", test.content))
+		checkParseError(t, test.name, err, test.want, test.message)
+	}
+
+	if _, err := MakeCityList(writeFile(t, "energy.csv", "\n,,\n")); err == nil {
+		t.Error("a file without cities was accepted")
+	}
+	if _, err := MakeCityList(filepath.Join(t.TempDir(), "missing.csv")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("a missing file: got %v", err)
+	}
+}
@@ -0,0 +1,326 @@
+/*Description: This file holds the Dataset, which is all of the city and
+panel data read from the data files, and the Store that hands the current
+Dataset to the web server's handlers and reloads it when the files change.*/
+
+package solar
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+//These are the paths of the data files. The files after Panels are
+//optional: they may be left empty or be missing on disk.
+type DataFiles struct {
+	Cities     string //energy.csv
+	Panels     string //solar.csv
+	Monthly    string //monthly.csv, 12 monthly solar radiation values per city
+	Batteries  string //batteries.csv, the home batteries to compare
+	Incentives string //incentives.json, the catalog of tax credits, rebates and payments for solar energy
+	Tariffs    string //Directory of the tariffs, one JSON file per tariff
+}
+
+//Gives the paths of all of the data files, for noticing changes. The
+//tariffs directory is listed too, so adding or removing a tariff is noticed.
+func (f DataFiles) list() []string {
+	files := []string{f.Cities, f.Panels, f.Monthly, f.Batteries, f.Incentives, f.Tariffs}
+	if f.Tariffs != "" {
+		tariffs, _ := filepath.Glob(filepath.Join(f.Tariffs, "*.json")) //the pattern is always valid
+		files = append(files, tariffs...)
+	}
+	return files
+}
+
+//This is the city, panel, battery, incentive and tariff data read from the
+//data files. A Dataset is never changed after LoadDataset returns it, so
+//any number of goroutines can read it at the same time.
+type Dataset struct {
+	Cities     map[string]City   //Cities by name
+	CityNames  []string          //City names in the order of the file (the heat map order)
+	Panels     map[string]Panel  //Panels by brand name
+	PanelNames []string          //Panel names in the order of the file
+	Index      *Index            //Spatial index of the cities, for finding the closest ones
+	Batteries  []Battery         //Batteries in the order of the file
+	Incentives []Incentive       //Incentives catalog, in the order of the file
+	Tariffs    map[string]Tariff //Tariffs by ID
+	TariffIDs  []string          //Tariff IDs in the order of the file names
+}
+
+//Reads and checks the data files. The error names the file, line and
+//column of the first bad value.
+func LoadDataset(files DataFiles) (*Dataset, error) {
+	cities, err := MakeCityList(files.Cities)
+	if err != nil {
+		return nil, err
+	}
+	panels, err := MakePanelList(files.Panels)
+	if err != nil {
+		return nil, err
+	}
+	if err := addMonthly(files.Monthly, cities); err != nil {
+		return nil, err
+	}
+	if err := findWeatherFiles(files.Cities, cities); err != nil {
+		return nil, err
+	}
+	tariffs, err := ReadTariffs(files.Tariffs)
+	if err != nil {
+		return nil, err
+	}
+	batteries, err := MakeBatteryList(files.Batteries)
+	if err != nil {
+		return nil, err
+	}
+	incentives, err := ReadIncentives(files.Incentives)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCityNames(cities, tariffs, incentives); err != nil {
+		return nil, err
+	}
+	data := &Dataset{
+		Cities:     make(map[string]City),
+		CityNames:  make([]string, 0, len(cities)),
+		Panels:     make(map[string]Panel),
+		PanelNames: make([]string, 0, len(panels)),
+		Batteries:  batteries,
+		Incentives: incentives,
+		Tariffs:    make(map[string]Tariff),
+		TariffIDs:  make([]string, 0, len(tariffs)),
+	}
+	for _, city := range cities {
+		data.Cities[city.Name] = city
+		data.CityNames = append(data.CityNames, city.Name)
+	}
+	for _, panel := range panels {
+		data.Panels[panel.Name] = panel
+		data.PanelNames = append(data.PanelNames, panel.Name)
+	}
+	for _, tariff := range tariffs {
+		data.Tariffs[tariff.ID] = tariff
+		data.TariffIDs = append(data.TariffIDs, tariff.ID)
+	}
+	data.Index = NewIndex(data.Cities)
+	return data, nil
+}
+
+//Makes the weather file paths of the cities relative to the directory of
+//the city data and checks that the files exist. The files themselves are
+//only read when a simulation needs them.
+func findWeatherFiles(cityFile string, cities []City) error {
+	for i := range cities {
+		if cities[i].WeatherFile == "" {
+			continue
+		}
+		if !filepath.IsAbs(cities[i].WeatherFile) {
+			cities[i].WeatherFile = filepath.Join(filepath.Dir(cityFile), cities[i].WeatherFile)
+		}
+		if _, err := os.Stat(cities[i].WeatherFile); err != nil {
+			return fmt.Errorf("%s: weather file of %s: %v", cityFile, cities[i].Name, err)
+		}
+	}
+	return nil
+}
+
+//Adds the monthly solar radiation of monthly.csv to the cities listed in
+//it. Without the file every city keeps the values SynthesizeMonthly works
+//out. A city in monthly.csv that isn't in energy.csv is an error, since it
+//is most likely a misspelled name.
+func addMonthly(filename string, cities []City) error {
+	if filename == "" {
+		return nil
+	}
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil
+	}
+	monthly, err := MakeMonthlyMap(filename)
+	if err != nil {
+		return err
+	}
+	for i := range cities {
+		if values, ok := monthly[cities[i].Name]; ok {
+			cities[i].MonthlyRad = &values
+			delete(monthly, cities[i].Name)
+		}
+	}
+	for name := range monthly {
+		return fmt.Errorf("%s: city %q is not in the city data", filename, name)
+	}
+	return nil
+}
+
+//Checks that every city a tariff serves or an incentive is offered in is
+//in the city data, since a city that isn't is most likely a misspelled name.
+func checkCityNames(cities []City, tariffs []Tariff, incentives []Incentive) error {
+	names := make(map[string]bool)
+	for _, city := range cities {
+		names[city.Name] = true
+	}
+	for _, tariff := range tariffs {
+		for _, name := range tariff.Cities {
+			if !names[name] {
+				return fmt.Errorf("tariff %s: city %q is not in the city data", tariff.ID, name)
+			}
+		}
+	}
+	for _, incentive := range incentives {
+		for _, name := range incentive.Cities {
+			if !names[name] {
+				return fmt.Errorf("incentive %s: city %q is not in the city data", incentive.ID, name)
+			}
+		}
+	}
+	return nil
+}
+
+//Gives all of the panels in the order of the file.
+func (d *Dataset) PanelList() []Panel {
+	panels := make([]Panel, 0, len(d.PanelNames))
+	for _, name := range d.PanelNames {
+		panels = append(panels, d.Panels[name])
+	}
+	return panels
+}
+
+//Gives the panel with the name, or the first panel of the file when name
+//is empty. ok is false if there is no such panel.
+func (d *Dataset) Panel(name string) (panel Panel, ok bool) {
+	if name == "" {
+		name = d.PanelNames[0] //LoadDataset makes sure there is one
+	}
+	panel, ok = d.Panels[name]
+	return panel, ok
+}
+
+//Gives all of the tariffs in the order of the file names.
+func (d *Dataset) TariffList() []Tariff {
+	tariffs := make([]Tariff, 0, len(d.TariffIDs))
+	for _, id := range d.TariffIDs {
+		tariffs = append(tariffs, d.Tariffs[id])
+	}
+	return tariffs
+}
+
+//Gives the tariff with the id. When id is empty it gives the first tariff
+//serving the city, or the default tariff if none does: the one in
+//default.json, or DefaultTariff. ok is false if there is no such tariff.
+func (d *Dataset) Tariff(id, city string) (tariff Tariff, ok bool) {
+	if id != "" {
+		tariff, ok = d.Tariffs[id]
+		return tariff, ok
+	}
+	for _, id := range d.TariffIDs {
+		if d.Tariffs[id].Serves(city) {
+			return d.Tariffs[id], true
+		}
+	}
+	if tariff, ok = d.Tariffs[DefaultTariffID]; ok {
+		return tariff, true
+	}
+	return DefaultTariff(), true
+}
+
+//Store holds the Dataset the server is using and the files it came from.
+//Reload swaps in a new Dataset only when the files pass all of the checks,
+//so a bad edit to a file never replaces good data. It is safe for
+//concurrent use.
+type Store struct {
+	Files DataFiles //Paths of the data files
+
+	mu      sync.RWMutex
+	data    *Dataset
+	stamps  []fileStamp //What the files looked like at the last reload attempt
+	loading sync.Mutex  //Only one reload at a time
+}
+
+//The modification time and size of a file, used to notice when it changes.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+//Makes a Store and loads the data files into it.
+func NewStore(files DataFiles) (*Store, error) {
+	s := &Store{Files: files}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+//Gives the current Dataset. Callers should call it once per request and use
+//that Dataset for the whole request, so a reload in the middle of the
+//request doesn't mix old and new data.
+func (s *Store) Dataset() *Dataset {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data
+}
+
+//Reads the data files again and swaps in the new Dataset. If a file can't
+//be read or has a bad value, the current Dataset is kept and the error is
+//returned.
+func (s *Store) Reload() error {
+	s.loading.Lock()
+	defer s.loading.Unlock()
+	s.stamps = s.statFiles()
+	data, err := LoadDataset(s.Files)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.data = data
+	s.mu.Unlock()
+	return nil
+}
+
+//Checks the data files every interval and reloads them when one of them
+//changed. onReload is called with the result of every reload. Watch returns
+//when stop is closed.
+func (s *Store) Watch(interval time.Duration, stop <-chan struct{}, onReload func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if s.changed() {
+				onReload(s.Reload())
+			}
+		}
+	}
+}
+
+//Reports whether a data file changed since the last reload attempt.
+func (s *Store) changed() bool {
+	s.loading.Lock()
+	defer s.loading.Unlock()
+	stamps := s.statFiles()
+	if len(stamps) != len(s.stamps) {
+		return true //a tariff was added or removed
+	}
+	for i := range stamps {
+		if stamps[i] != s.stamps[i] {
+			return true
+		}
+	}
+	return false
+}
+
+//Gives the stamps of the data files. A missing file gets a zero stamp.
+func (s *Store) statFiles() []fileStamp {
+	files := s.Files.list()
+	stamps := make([]fileStamp, 0, len(files))
+	for _, filename := range files {
+		var stamp fileStamp
+		if info, err := os.Stat(filename); err == nil {
+			stamp = fileStamp{info.ModTime(), info.Size()}
+		}
+		stamps = append(stamps, stamp)
+	}
+	return stamps
+}
@@ -0,0 +1,267 @@
+package solar
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//Writes the data files named in files (with the tariffs directory as
+//"tariffs/<id>.json") to a new directory and gives their paths. Good
+//energy.csv and solar.csv are written unless files replaces them.
+func dataDir(t *testing.T, files map[string]string) DataFiles {
+	t.Helper()
+	dir := t.TempDir()
+	contents := map[string]string{
+		"energy.csv": albuquerque + "\nAnaheim,33.8366,117.9143,67.05,3.99,29.3,4.65,557,3.59,Semper Solaris,,CA\n",
+		"solar.csv":  "Kyocera,16,315,2.19,399,-0.46,45,0.8,0\n",
+	}
+	for name, content := range files {
+		contents[name] = content
+	}
+	if err := os.Mkdir(filepath.Join(dir, "tariffs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range contents {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return DataFiles{
+		Cities:     filepath.Join(dir, "energy.csv"),
+		Panels:     filepath.Join(dir, "solar.csv"),
+		Monthly:    filepath.Join(dir, "monthly.csv"),
+		Batteries:  filepath.Join(dir, "batteries.csv"),
+		Incentives: filepath.Join(dir, "incentives.json"),
+		Tariffs:    filepath.Join(dir, "tariffs"),
+	}
+}
+
+func TestLoadDataset(t *testing.T) {
+	//Only energy.csv and solar.csv: the other files are optional.
+	data, err := LoadDataset(dataDir(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Cities) != 2 || data.CityNames[1] != "Anaheim" || data.Cities["Anaheim"].MonthlyRad != nil ||
+		len(data.PanelNames) != 1 || len(data.Batteries) != 0 || len(data.Incentives) != 0 {
+		t.Errorf("got %d cities, %d panels, %d batteries and %d incentives", len(data.Cities), len(data.PanelNames), len(data.Batteries), len(data.Incentives))
+	}
+	if panel, ok := data.Panel(""); !ok || panel.Name != "Kyocera" {
+		t.Errorf("the first panel: got %q, %v", panel.Name, ok)
+	}
+	if tariff, ok := data.Tariff("", "Anaheim"); !ok || tariff.ID != DefaultTariffID {
+		t.Errorf("no tariffs: got %q, %v, want the default tariff", tariff.ID, ok)
+	}
+	nearest, err := NearestCity(data.Index, 33.8, -117.9, 0)
+	if err != nil || nearest.City != "Anaheim" {
+		t.Errorf("the index: got %q, %v", nearest.City, err)
+	}
+
+	data, err = LoadDataset(dataDir(t, map[string]string{
+		"monthly.csv":        "Anaheim,1,2,3,4,5,6,7,8,9,10,11,12\n",
+		"batteries.csv":      "Home,13.5,5,90,12000\n",
+		"incentives.json":    `[{"id": "ca", "name": "a", "states": ["CA"], "type": "fixed", "amount": 500}]`,
+		"tariffs/sce-1.json": `{"name": "flat", "cities": ["Anaheim"], "rate": 0.3}`,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Cities["Anaheim"].MonthlyRad == nil || len(data.Batteries) != 1 || len(data.Incentives) != 1 {
+		t.Errorf("the optional files weren't read: %+v", data)
+	}
+	if tariff, _ := data.Tariff("", "Anaheim"); tariff.ID != "sce-1" {
+		t.Errorf("Anaheim's tariff: got %q, want sce-1", tariff.ID)
+	}
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		err   string //the start of the error, after the directory
+	}{
+		{"bad city", map[string]string{"energy.csv": "Anaheim,33.8366,117.9143,warm,3.99,29.3,4.65,557,3.59,Semper Solaris\n"}, `energy.csv:1: column 4: bad value "warm"`},
+		{"bad panel", map[string]string{"solar.csv": "Kyocera,16,315,2.19,399,-0.46,45,0.8,15\n"}, `solar.csv:1: column 9: bad value "15"`},
+		{"bad month", map[string]string{"monthly.csv": "Anaheim,1,2,3\n"}, "monthly.csv:1: expected 13 columns"},
+		{"bad battery", map[string]string{"batteries.csv": "Home,13.5,5,110,12000\n"}, `batteries.csv:1: column 4: bad value "110"`},
+		{"missing weather file", map[string]string{"energy.csv": albuquerque[:len(albuquerque)-3] + "tmy/723650TYA.CSV,NM\n"}, "energy.csv: weather file of Albuquerque"},
+		{"tariff of an unknown city", map[string]string{"tariffs/pnm.json": `{"name": "flat", "cities": ["Albuquerqe"], "rate": 0.1}`}, `tariff pnm: city "Albuquerqe" is not in the city data`},
+	}
+	for _, test := range tests {
+		_, err := LoadDataset(dataDir(t, test.files))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got %v, want an error with %q", test.name, err, test.err)
+		}
+	}
+	files := dataDir(t, nil)
+	files.Cities = filepath.Join(filepath.Dir(files.Cities), "cities.csv")
+	if _, err := LoadDataset(files); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("a missing energy.csv: got %v", err)
+	}
+}
+
+func TestStoreReload(t *testing.T) {
+	files := dataDir(t, nil)
+	store, err := NewStore(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := store.Dataset()
+
+	//A bad edit keeps the data the store had.
+	if err := os.WriteFile(files.Panels, []byte("Kyocera,16,315,2.19,399,-0.46,45,0.8,15\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Reload(); err == nil || !strings.Contains(err.Error(), "solar.csv:1: column 9") {
+		t.Errorf("a bad panel: got %v", err)
+	}
+	if store.Dataset() != old || old.Panels["Kyocera"].LID != 0 {
+		t.Error("a failed reload replaced the data")
+	}
+
+	//A good edit is swapped in; the Dataset handed out before is unchanged.
+	if err := os.WriteFile(files.Panels, []byte("Kyocera,16,315,2.19,399\nSamsung,15.62,250,1.6,300\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if data := store.Dataset(); data == old || len(data.PanelNames) != 2 {
+		t.Errorf("got %d panels after the reload, want 2", len(store.Dataset().PanelNames))
+	}
+	if len(old.PanelNames) != 1 || len(old.Panels) != 1 {
+		t.Errorf("the old Dataset changed to %d panels", len(old.Panels))
+	}
+
+	if _, err := NewStore(dataDir(t, map[string]string{"energy.csv": "\n"})); err == nil {
+		t.Error("NewStore accepted a file without cities")
+	}
+}
+
+func TestStoreSwapsWholeDatasets(t *testing.T) {
+	files := dataDir(t, nil)
+	store, err := NewStore(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//Reloads switch between one and two panels while readers check that
+	//every Dataset they get is whole: its names and map agree.
+	versions := []string{"Kyocera,16,315,2.19,399\n", "Kyocera,16,315,2.19,399\nSamsung,15.62,250,1.6,300\n"}
+	done := make(chan struct{})
+	errs := make(chan string, 4)
+	for r := 0; r < 4; r++ {
+		go func() {
+			for {
+				select {
+				case <-done:
+					errs <- ""
+					return
+				default:
+				}
+				data := store.Dataset()
+				if n := len(data.PanelNames); n != len(data.Panels) || n < 1 || n > 2 || data.Panels[data.PanelNames[n-1]].Name == "" {
+					errs <- "a reader got a Dataset with names and panels that don't agree"
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 40; i++ {
+		if err := os.WriteFile(files.Panels, []byte(versions[i%2]), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Reload(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	for r := 0; r < 4; r++ {
+		if err := <-errs; err != "" {
+			t.Error(err)
+		}
+	}
+}
+
+func TestStoreChanged(t *testing.T) {
+	files := dataDir(t, nil)
+	store, err := NewStore(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.changed() {
+		t.Error("nothing changed but changed() is true")
+	}
+	//The same size, a new modification time.
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(files.Panels, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if !store.changed() {
+		t.Error("touching solar.csv wasn't noticed")
+	}
+	//A failed reload is not tried again until a file changes again.
+	if err := os.WriteFile(files.Panels, []byte("Kyocera,16,315,2.19,399,-0.46,45,0.8,15\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !store.changed() || store.Reload() == nil || store.changed() {
+		t.Error("a bad solar.csv: want changed, a failed reload, then unchanged")
+	}
+	tests := []struct {
+		name, file string
+	}{
+		{"a tariff added", "tariffs/pnm.json"},
+		{"an optional file added", "batteries.csv"},
+	}
+	for _, test := range tests {
+		store.Reload() //failed or not, it takes the stamps of the files
+		if err := os.WriteFile(filepath.Join(filepath.Dir(files.Cities), test.file), []byte("\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if !store.changed() {
+			t.Errorf("%s wasn't noticed", test.name)
+		}
+	}
+}
+
+func TestStoreWatch(t *testing.T) {
+	files := dataDir(t, nil)
+	store, err := NewStore(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := store.Dataset()
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	reloads := make(chan error, 10)
+	go func() {
+		store.Watch(time.Millisecond, stop, func(err error) { reloads <- err })
+		close(stopped)
+	}()
+
+	//Many ticks with the files unchanged.
+	time.Sleep(50 * time.Millisecond)
+	if len(reloads) != 0 || store.Dataset() != old {
+		t.Errorf("the unchanged files were reloaded %d times", len(reloads))
+	}
+
+	if err := os.WriteFile(files.Panels, []byte("Kyocera,16,315,2.19,399\nSamsung,15.62,250,1.6,300\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-reloads:
+		if err != nil || len(store.Dataset().PanelNames) != 2 {
+			t.Errorf("got %v and %d panels, want the 2 panels of the new solar.csv", err, len(store.Dataset().PanelNames))
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the new solar.csv wasn't reloaded")
+	}
+
+	close(stop)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Error("Watch didn't return when stopped")
+	}
+}
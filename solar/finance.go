@@ -0,0 +1,144 @@
+/*Description: This file works out whether panels pay for themselves: the
+years until the savings on the electricity bill cover the cost (payback),
+the net present value and internal rate of return of the savings, and the
+levelized cost of energy (the cost of every kwh the panels make).*/
+
+package solar
+
+import "math"
+
+//These are the money values of a financial analysis. Percentages are per year.
+type FinanceInputs struct {
+	Price      float64 `json:"price"`      //Flat electricity price in the first year (dollars per kwh), 0 to use the tariff
+	Escalation float64 `json:"escalation"` //Yearly rise of the electricity price (percentage)
+	Discount   float64 `json:"discount"`   //Discount rate for the net present value and LCOE (percentage)
+	OMCost     float64 `json:"omCost"`     //Operation and maintenance (dollars per kw of panels per year)
+
+	LoanRate       float64 `json:"loanRate"`       //Yearly interest rate of a loan (percentage)
+	LoanYears      int     `json:"loanYears"`      //Years to pay back a loan
+	LeasePayment   float64 `json:"leasePayment"`   //Monthly lease payment in the first year (dollars), 0 to price it like the PPA
+	LeaseEscalator float64 `json:"leaseEscalator"` //Yearly rise of the lease payment (percentage)
+	LeaseYears     int     `json:"leaseYears"`     //Years of a lease
+	PPAPrice       float64 `json:"ppaPrice"`       //PPA price in the first year (dollars per kwh), 0 for 80% of what a kwh saves on the bill
+	PPAEscalator   float64 `json:"ppaEscalator"`   //Yearly rise of the PPA price (percentage)
+	PPAYears       int     `json:"ppaYears"`       //Years of a PPA
+
+	Income []float64 `json:"-"` //Incentive payments in each year of the forecast (dollars)
+}
+
+//Gives the default money values: the tariff's rates rising 2.5% a year, a
+//5% discount rate, $20 per kw a year of upkeep, a 20 year loan at 7%, and
+//a 25 year lease and PPA rising 2.9% a year.
+func DefaultFinance() FinanceInputs {
+	return FinanceInputs{
+		Escalation: 2.5, Discount: 5, OMCost: 20,
+		LoanRate: 7, LoanYears: 20,
+		LeaseEscalator: 2.9, LeaseYears: 25,
+		PPAEscalator: 2.9, PPAYears: 25,
+	}
+}
+
+//This is the result of a financial analysis.
+type Finance struct {
+	Payback *float64 `json:"payback"` //Years until the savings pay back the cost, null if they don't within the forecast
+	NPV     float64  `json:"npv"`     //Net present value of the cost and savings (dollars)
+	IRR     *float64 `json:"irr"`     //Internal rate of return (percentage), null if there is none
+	LCOE    float64  `json:"lcoe"`    //Levelized cost of energy (dollars per kwh)
+	Savings float64  `json:"savings"` //Bill savings less upkeep over the forecast (dollars)
+}
+
+//Analyzes a system costing cost dollars, with panels rated kw kilowatts,
+//that produces the forecast. The output saves its value on the bill at the
+//electricity price of each year and earns the incentive income; the upkeep
+//is paid every year.
+func Analyze(cost, kw float64, forecast []YearOutput, in FinanceInputs) Finance {
+	flows := make([]float64, len(forecast)+1) //money in each year, year 0 is the purchase
+	flows[0] = -cost
+	var energy, upkeep float64 //discounted
+	var finance Finance
+	cumulative := -cost
+	for i, year := range forecast {
+		price := in.Price * math.Pow(1+in.Escalation/100, float64(i))
+		om := in.OMCost * kw
+		flows[i+1] = year.Output*price - om
+		if i < len(in.Income) {
+			flows[i+1] += in.Income[i]
+		}
+		discount := math.Pow(1+in.Discount/100, float64(year.Year))
+		energy += year.Output / discount
+		upkeep += om / discount
+		finance.Savings += flows[i+1]
+		if finance.Payback == nil && cumulative+flows[i+1] >= 0 && flows[i+1] > 0 {
+			payback := float64(i) + -cumulative/flows[i+1] //part of the year that finishes paying it back
+			payback = float64(int(payback*10)) / 10
+			finance.Payback = &payback
+		}
+		cumulative += flows[i+1]
+	}
+	finance.NPV = float64(int(NPV(flows, in.Discount)*100)) / 100
+	finance.Savings = float64(int(finance.Savings*100)) / 100
+	if irr, ok := IRR(flows); ok {
+		irr = float64(int(irr*100)) / 100
+		finance.IRR = &irr
+	}
+	if energy > 0 {
+		finance.LCOE = float64(int((cost+upkeep)/energy*1000)) / 1000
+	}
+	return finance
+}
+
+//Gives the net present value of yearly money flows, the first one now, at
+//a discount rate (percentage).
+func NPV(flows []float64, rate float64) float64 {
+	var npv float64
+	for year, flow := range flows {
+		npv += flow / math.Pow(1+rate/100, float64(year))
+	}
+	return npv
+}
+
+//Gives the internal rate of return (percentage) of yearly money flows, the
+//rate at which their net present value is 0. ok is false if there is no
+//such rate between -99% and 1000%.
+func IRR(flows []float64) (irr float64, ok bool) {
+	low, high := -99.0, 1000.0
+	npvLow, npvHigh := NPV(flows, low), NPV(flows, high)
+	if math.IsNaN(npvLow) || math.IsNaN(npvHigh) || (npvLow > 0) == (npvHigh > 0) {
+		return 0, false
+	}
+	for i := 0; i < 200 && high-low > 1e-9; i++ { //bisection
+		mid := (low + high) / 2
+		if npvMid := NPV(flows, mid); (npvMid > 0) == (npvLow > 0) {
+			low, npvLow = mid, npvMid
+		} else {
+			high = mid
+		}
+	}
+	return (low + high) / 2, true
+}
+
+//Adds the financial analysis of every panel option. options are in the
+//order of panels, like CalcCostBrand gives them. Every option is the
+//system that is bought: its Installed output, forecast over years with the
+//panel's degradation, and its Kw of upkeep. A kwh of the output is worth
+//what it saves on the bills in the first year. The cost is the NetCost,
+//and the incentives paid for every kwh get what they pay over all of their
+//years. Every option also gets the comparison of the ways of paying for
+//it, with the forecast carried on to the end of the longest contract.
+func AnalyzeOptions(options []PanelOption, panels []Panel, losses Losses, years int, in FinanceInputs, bills BillModel) {
+	for i := range options {
+		panel := panels[i]
+		annual := options[i].Installed * 12 / (1 - losses.LID/100) //before LID, the forecast adds the panel's own
+		forecast := Forecast(annual, panel, FinancingYears(years, in))
+		in.Price = bills.Value(forecast[0].Output)
+		in.Income = make([]float64, len(forecast))
+		for k, incentive := range options[i].Incentives {
+			if incentive.PerKwh > 0 && incentive.Reason == "" {
+				options[i].Incentives[k].Amount = incentive.addIncome(forecast, in.Income)
+			}
+		}
+		finance := Analyze(float64(options[i].NetCost), options[i].Kw, forecast[:years], in)
+		options[i].Finance = &finance
+		options[i].Financing = CompareFinancing(float64(options[i].NetCost), options[i].Kw, forecast, years, in)
+	}
+}
@@ -0,0 +1,280 @@
+package solar
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+//Gives a forecast of the same output every year.
+func flatForecast(output float64, years int) []YearOutput {
+	forecast := make([]YearOutput, years)
+	for i := range forecast {
+		forecast[i] = YearOutput{Year: i + 1, Output: output, Share: 100}
+	}
+	return forecast
+}
+
+func TestIRR(t *testing.T) {
+	tests := []struct {
+		name  string
+		flows []float64
+		irr   float64
+		ok    bool
+	}{
+		{"one year", []float64{-1000, 1100}, 10, true},
+		{"two years", []float64{-1000, 0, 1210}, 10, true},
+		//60x² + 60x = 100 with x = 1/(1+irr)
+		{"even payments", []float64{-100, 60, 60}, 13.066238629, true},
+		{"losing money", []float64{-1000, 100, 100}, -62.984378813, true},
+		{"just above the lowest rate", []float64{-100, 1.02}, -98.98, true},
+		{"just below the highest rate", []float64{-1, 10.5}, 950, true},
+		{"below the lowest rate", []float64{-100, 0.5}, 0, false},
+		{"above the highest rate", []float64{-1, 12}, 0, false},
+		{"never negative", []float64{100, 100}, 0, false},
+		{"never positive", []float64{-100, -20}, 0, false},
+	}
+	for _, test := range tests {
+		irr, ok := IRR(test.flows)
+		if ok != test.ok || ok && math.Abs(irr-test.irr) > 1e-6 {
+			t.Errorf("%s: got %g, %v; want %g, %v", test.name, irr, ok, test.irr, test.ok)
+		}
+	}
+}
+
+func TestNPV(t *testing.T) {
+	if got := NPV([]float64{-1000, 550, 550}, 10); math.Abs(got-(-1000+500+550/1.21)) > 1e-9 {
+		t.Errorf("got %g", got)
+	}
+	if got := NPV([]float64{-1000, 550, 550}, 0); got != 100 {
+		t.Errorf("no discount: got %g, want 100", got)
+	}
+}
+
+//Gives a pointer to x, for the results that may be null.
+func number(x float64) *float64 {
+	return &x
+}
+
+//Reports whether two results that may be null are the same.
+func sameNumber(a, b *float64) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+//Writes a result that may be null.
+func showNumber(x *float64) string {
+	if x == nil {
+		return "null"
+	}
+	return fmt.Sprint(*x)
+}
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name     string
+		cost, kw float64
+		forecast []YearOutput
+		in       FinanceInputs
+		want     Finance
+	}{
+		//-1000, 550, 550 at 10%: 500 + 454.55 comes back. 1.8 years is
+		//the second year's 550 paying the last 450.
+		{"negative NPV", 1000, 1, flatForecast(1000, 2), FinanceInputs{Price: 0.55, Discount: 10},
+			Finance{Payback: number(1.8), NPV: -45.45, IRR: number(6.59), LCOE: 0.576, Savings: 1100}},
+		{"no payback", 1000, 1, flatForecast(1000, 2), FinanceInputs{Price: 0.1, Discount: 10},
+			Finance{NPV: -826.44, IRR: number(-62.98), LCOE: 0.576, Savings: 200}},
+		//200 - 20 of upkeep + 50 of income, then 220 - 20.
+		{"escalation, upkeep and income", 1000, 2, flatForecast(1000, 2),
+			FinanceInputs{Price: 0.2, Escalation: 10, OMCost: 10, Income: []float64{50}},
+			Finance{NPV: -570, IRR: number(-42.32), LCOE: 0.52, Savings: 430}},
+		{"paid back in the first year", 100, 1, flatForecast(1000, 3), FinanceInputs{Price: 0.2},
+			Finance{Payback: number(0.5), NPV: 500, IRR: number(191.96), LCOE: 0.033, Savings: 600}},
+		{"nothing saved", 1000, 1, flatForecast(1000, 2), FinanceInputs{OMCost: 5},
+			Finance{NPV: -1010, LCOE: 0.505, Savings: -10}},
+	}
+	for _, test := range tests {
+		got := Analyze(test.cost, test.kw, test.forecast, test.in)
+		if got.NPV != test.want.NPV || got.LCOE != test.want.LCOE || got.Savings != test.want.Savings {
+			t.Errorf("%s: got NPV %g, LCOE %g, savings %g; want %g, %g, %g",
+				test.name, got.NPV, got.LCOE, got.Savings, test.want.NPV, test.want.LCOE, test.want.Savings)
+		}
+		if !sameNumber(got.Payback, test.want.Payback) || !sameNumber(got.IRR, test.want.IRR) {
+			t.Errorf("%s: got payback %s and IRR %s, want %s and %s", test.name,
+				showNumber(got.Payback), showNumber(got.IRR), showNumber(test.want.Payback), showNumber(test.want.IRR))
+		}
+	}
+}
+
+func TestAnalyzeOptions(t *testing.T) {
+	load := make([]float64, HoursPerYear)
+	shape := make([]float64, HoursPerYear)
+	for h := range load {
+		load[h], shape[h] = 1, 1.0/HoursPerYear //the panels never make more than the home uses
+	}
+	bills := BillModel{Tariff: FlatTariff(0.2), Load: load, Shape: shape}
+	panels := []Panel{{Name: "Even", Efficiency: 20, Area: 2}}
+	options := []PanelOption{{
+		Name:       "Even",
+		Output:     100,
+		Installed:  100, //1200 kwh a year, worth 0.2 a kwh
+		NetCost:    500,
+		Incentives: []AppliedIncentive{{ID: "pbi", PerKwh: 0.05, Years: 2}},
+	}}
+	AnalyzeOptions(options, panels, Losses{}, 3, FinanceInputs{}, bills)
+	finance := options[0].Finance
+	if finance == nil {
+		t.Fatal("no finance")
+	}
+	//-500, then 240 + 60, 240 + 60 and 240.
+	if finance.Payback == nil || *finance.Payback != 1.6 {
+		t.Errorf("got payback %v, want 1.6", finance.Payback)
+	}
+	if math.Abs(finance.NPV-340) > 0.1 || math.Abs(finance.Savings-840) > 0.1 {
+		t.Errorf("got NPV %g and savings %g, want 340 and 840", finance.NPV, finance.Savings)
+	}
+	if got := options[0].Incentives[0].Amount; got != 120 {
+		t.Errorf("the payments for every kwh add up to %g, want 120", got)
+	}
+	if len(options[0].Financing) == 0 {
+		t.Error("the ways of paying weren't compared")
+	}
+}
+
+func TestAnalyzeOptionsPaysForThePanelsBought(t *testing.T) {
+	//A home using 10 kwh every hour uses all of the output, worth 0.2 a kwh.
+	bills := BillModel{Tariff: FlatTariff(0.2), Load: make([]float64, HoursPerYear), Shape: make([]float64, HoursPerYear)}
+	for h := range bills.Load {
+		bills.Load[h], bills.Shape[h] = 10, 1.0/HoursPerYear
+	}
+	city := City{SolarRad: 5}
+	panels := []Panel{{Name: "Twenty", Efficiency: 20, Area: 2, Price: 300}}
+	losses := Losses{InverterEfficiency: 100}
+	in := FinanceInputs{OMCost: 20}
+	//The roof makes 2825.8 kwh a month; 600 of them take 9 of the 46 panels
+	//that fit, and twice as much 19.
+	tests := []struct {
+		usage     float64
+		numPanels int
+		kw        float64
+	}{
+		{600, 9, 3.6},
+		{1200, 19, 7.6},
+		{100000, 46, 18.4},
+	}
+	for _, test := range tests {
+		options := CalcCostBrand(test.usage, 1000, city, Orientation{}, losses, panels)
+		AnalyzeOptions(options, panels, losses, 2, in, bills)
+		option := options[0]
+		installed := 2825.8 * float64(test.numPanels) * 2 / 92.903
+		if option.NumPanels != test.numPanels || option.Kw != test.kw || math.Abs(option.Installed-installed) > 0.1 {
+			t.Errorf("%g kwh: got %d panels of %g kw making %g kwh a month, want %d of %g making %.2f",
+				test.usage, option.NumPanels, option.Kw, option.Installed, test.numPanels, test.kw, installed)
+		}
+		//Two years of the panels' output on the bill, less their upkeep.
+		savings := 2 * (option.Installed*12*0.2 - 20*option.Kw)
+		if math.Abs(option.Finance.Savings-savings) > 0.02 {
+			t.Errorf("%g kwh: got savings of %g, want %.2f", test.usage, option.Finance.Savings, savings)
+		}
+		lcoe := (float64(option.NetCost) + 2*20*option.Kw) / (2 * option.Installed * 12)
+		if math.Abs(option.Finance.LCOE-lcoe) > 0.001 {
+			t.Errorf("%g kwh: got an LCOE of %g, want %.3f", test.usage, option.Finance.LCOE, lcoe)
+		}
+	}
+}
+
+func TestLoanPayment(t *testing.T) {
+	if got := LoanPayment(10000, 6, 30); math.Abs(got-59.955) > 0.001 {
+		t.Errorf("10000 at 6%% for 30 years: got %g a month, want 59.955", got)
+	}
+	if got := LoanPayment(12000, 0, 10); got != 100 {
+		t.Errorf("no interest: got %g a month, want 100", got)
+	}
+}
+
+func TestCompareFinancing(t *testing.T) {
+	in := FinanceInputs{
+		Price: 0.1, Income: []float64{120},
+		LoanYears: 10, LeasePayment: 20, LeaseYears: 4, PPAPrice: 0.05, PPAYears: 6,
+	}
+	if got := FinancingYears(3, in); got != 10 {
+		t.Errorf("FinancingYears: got %d, want the 10 years of the loan", got)
+	}
+	//1200 kwh a year avoid a bill of 10 dollars a month.
+	options := CompareFinancing(12000, 1, flatForecast(1200, 10), 3, in)
+	want := []struct {
+		kind  string
+		term  int
+		total float64
+	}{
+		{Cash, 3, -12000 + 36*10 + 120}, //the first year's income too
+		{Loan, 10, 120*(10-100) + 120},  //100 a month for the whole 10 years
+		{Lease, 4, 48 * (10 - 20)},
+		{PPA, 6, 72 * (10 - 5)},
+	}
+	if len(options) != len(want) {
+		t.Fatalf("got %d ways of paying, want %d", len(options), len(want))
+	}
+	for i, w := range want {
+		got := options[i]
+		if got.Kind != w.kind || got.Term != w.term || len(got.Yearly) != w.term || math.Abs(got.Total-w.total) > 0.011 {
+			t.Errorf("got %s over %d years (%d rows) totaling %g, want %s over %d totaling %g",
+				got.Kind, got.Term, len(got.Yearly), got.Total, w.kind, w.term, w.total)
+		}
+	}
+	if row := options[1].Yearly[0]; row.Payment != 100 || row.AvoidedBill != 10 || row.Income != 10 || row.Net != -80 {
+		t.Errorf("the loan's first year: got %+v", row)
+	}
+}
+
+func TestCompareFinancingOfPanelsBought(t *testing.T) {
+	bills := BillModel{Tariff: FlatTariff(0.2), Load: make([]float64, HoursPerYear), Shape: make([]float64, HoursPerYear)}
+	for h := range bills.Load {
+		bills.Load[h], bills.Shape[h] = 10, 1.0/HoursPerYear
+	}
+	panels := []Panel{{Name: "Twenty", Efficiency: 20, Area: 2, Price: 300}}
+	losses := Losses{InverterEfficiency: 100}
+	in := FinanceInputs{OMCost: 20, LoanRate: 6, LoanYears: 10, LeaseYears: 10, PPAYears: 10}
+	//9 and 19 of the 46 panels that fit on the roof.
+	for _, usage := range []float64{600, 1200} {
+		options := CalcCostBrand(usage, 1000, City{SolarRad: 5}, Orientation{}, losses, panels)
+		AnalyzeOptions(options, panels, losses, 10, in, bills)
+		option := options[0]
+		//Every way of paying gets the bill of the panels bought, and the
+		//PPA and lease sell their output for 80% of it.
+		avoided := option.Installed * 0.2
+		want := map[string]float64{
+			Cash:  20 * option.Kw / 12,
+			Loan:  20*option.Kw/12 + LoanPayment(float64(option.NetCost), 6, 10),
+			Lease: 0.8 * avoided,
+			PPA:   0.8 * avoided,
+		}
+		for _, financing := range option.Financing {
+			row := financing.Yearly[0]
+			if math.Abs(row.AvoidedBill-avoided) > 0.011 || math.Abs(row.Payment-want[financing.Kind]) > 0.011 {
+				t.Errorf("%d panels, %s: got a payment of %g against a bill of %g, want %.2f against %.2f",
+					option.NumPanels, financing.Kind, row.Payment, row.AvoidedBill, want[financing.Kind], avoided)
+			}
+		}
+	}
+}
+
+func TestAnalyzeOptionsRunsEveryTerm(t *testing.T) {
+	bills := BillModel{Tariff: FlatTariff(0.2), Load: make([]float64, HoursPerYear), Shape: make([]float64, HoursPerYear)}
+	for h := range bills.Load {
+		bills.Load[h], bills.Shape[h] = 1, 1.0/HoursPerYear
+	}
+	options := []PanelOption{{Name: "Aging", Output: 100, Installed: 100, Kw: 0.4, NetCost: 20000}}
+	panels := []Panel{{Name: "Aging", Efficiency: 20, Area: 2, Degradation: 1}}
+	AnalyzeOptions(options, panels, Losses{}, 5, DefaultFinance(), bills)
+	terms := map[string]int{Cash: 5, Loan: 20, Lease: 25, PPA: 25}
+	for _, financing := range options[0].Financing {
+		if financing.Term != terms[financing.Kind] || len(financing.Yearly) != financing.Term {
+			t.Errorf("%s: got a term of %d years with %d rows, want %d", financing.Kind, financing.Term, len(financing.Yearly), terms[financing.Kind])
+		}
+	}
+	//The output past the 5 forecast years keeps degrading.
+	loan := options[0].Financing[1].Yearly
+	if loan[19].AvoidedBill >= loan[5].AvoidedBill*math.Pow(1.025, 14) {
+		t.Errorf("the loan's last year avoids %g, no less than with panels that don't degrade", loan[19].AvoidedBill)
+	}
+}
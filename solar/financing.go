@@ -0,0 +1,120 @@
+/*Description: This file compares the ways of paying for panels: buying
+them with cash, with a loan, leasing them, or buying their output with a
+power purchase agreement (PPA). Every way is a month by month cash flow
+over its contract term, against the electricity bill the panels avoid.*/
+
+package solar
+
+import "math"
+
+//Ways of paying for panels, in the order CompareFinancing gives them.
+const (
+	Cash  = "cash"  //The panels are bought up front
+	Loan  = "loan"  //The panels are bought with a loan of their cost
+	Lease = "lease" //The panels are rented for a monthly payment
+	PPA   = "ppa"   //Their output is bought for a price per kwh
+)
+
+//This is one way of paying for panels over its term.
+type Financing struct {
+	Kind    string          `json:"kind"`    //Cash, Loan, Lease or PPA
+	Term    int             `json:"term"`    //Years of the contract, for cash the years of the forecast
+	Upfront float64         `json:"upfront"` //Paid at the start (dollars)
+	Total   float64         `json:"total"`   //Avoided bills and incentive income less all of the payments over the term (dollars)
+	Yearly  []FinancingYear `json:"yearly"`  //Monthly cash flow in each year of the term
+}
+
+//This is the cash flow of an average month in one year of a contract.
+type FinancingYear struct {
+	Year        int     `json:"year"`        //1 for the first year
+	Payment     float64 `json:"payment"`     //Loan, lease or PPA payment, and the upkeep of owned panels (dollars)
+	AvoidedBill float64 `json:"avoidedBill"` //Bill the panels avoid (dollars)
+	Income      float64 `json:"income"`      //Incentive income of owned panels (dollars)
+	Net         float64 `json:"net"`         //AvoidedBill and Income less Payment (dollars)
+}
+
+//Gives the monthly payment of a loan of principal dollars at a yearly
+//interest rate (percentage) paid back over years.
+func LoanPayment(principal, rate float64, years int) float64 {
+	months := float64(years * 12)
+	if rate == 0 {
+		return principal / months
+	}
+	r := rate / 100 / 12
+	return principal * r / (1 - math.Pow(1+r, -months))
+}
+
+//Compares paying cash, a loan, a lease and a PPA for panels costing cost
+//dollars, rated kw kilowatts, that produce the forecast; all three are of
+//the same panels, those that are bought (see AnalyzeOptions). The owners of the
+//panels (cash and loan) pay the upkeep and get the incentive income; the
+//leasing company or PPA provider does for a lease and a PPA. The PPA price
+//and lease payment of in are used when they are given, otherwise the PPA
+//sells a kwh for 80% of what it saves on the bill in the first year and
+//the lease payment is the PPA's first year payment spread over the months.
+//Cash is compared over years; the loan, lease and PPA run over their whole
+//terms, so forecast must cover years and the longest term (see
+//FinancingYears).
+func CompareFinancing(cost, kw float64, forecast []YearOutput, years int, in FinanceInputs) []Financing {
+	ppaPrice := in.PPAPrice
+	if ppaPrice == 0 {
+		ppaPrice = 0.8 * in.Price
+	}
+	leasePayment := in.LeasePayment
+	if leasePayment == 0 && len(forecast) > 0 {
+		leasePayment = forecast[0].Output * ppaPrice / 12
+	}
+	loanPayment := LoanPayment(cost, in.LoanRate, in.LoanYears)
+	upkeep := in.OMCost * kw / 12
+
+	options := []Financing{
+		{Kind: Cash, Term: years, Upfront: cost},
+		{Kind: Loan, Term: in.LoanYears},
+		{Kind: Lease, Term: in.LeaseYears},
+		{Kind: PPA, Term: in.PPAYears},
+	}
+	for k := range options {
+		option := &options[k]
+		if option.Term > len(forecast) {
+			option.Term = len(forecast) //too short a forecast, only for a caller that didn't use FinancingYears
+		}
+		option.Total = -option.Upfront
+		for y := 0; y < option.Term; y++ {
+			row := FinancingYear{Year: y + 1}
+			row.AvoidedBill = forecast[y].Output * in.Price * math.Pow(1+in.Escalation/100, float64(y)) / 12
+			switch option.Kind {
+			case Cash, Loan:
+				row.Payment = upkeep
+				if option.Kind == Loan {
+					row.Payment += loanPayment
+				}
+				if y < len(in.Income) {
+					row.Income = in.Income[y] / 12
+				}
+			case Lease:
+				row.Payment = leasePayment * math.Pow(1+in.LeaseEscalator/100, float64(y))
+			case PPA:
+				row.Payment = forecast[y].Output * ppaPrice * math.Pow(1+in.PPAEscalator/100, float64(y)) / 12
+			}
+			row.Net = row.AvoidedBill + row.Income - row.Payment
+			option.Total += 12 * row.Net
+			for _, value := range []*float64{&row.Payment, &row.AvoidedBill, &row.Income, &row.Net} {
+				*value = float64(int(*value*100)) / 100
+			}
+			option.Yearly = append(option.Yearly, row)
+		}
+		option.Total = float64(int(option.Total*100)) / 100
+	}
+	return options
+}
+
+//Gives the years a forecast must cover to compare the ways of paying over
+//years: the longest of years and the terms of the loan, lease and PPA.
+func FinancingYears(years int, in FinanceInputs) int {
+	for _, term := range []int{in.LoanYears, in.LeaseYears, in.PPAYears} {
+		if term > years {
+			years = term
+		}
+	}
+	return years
+}
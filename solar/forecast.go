@@ -0,0 +1,49 @@
+/*Description: This file projects the output of panels over their
+lifetime. Panels lose some power in their first hours in the sun (light
+induced degradation, LID) and then a little more every year, which is
+what panel warranties promise against.*/
+
+package solar
+
+//Values used when a panel's datasheet values aren't known.
+const (
+	DefaultDegradation = 0.5 //Power lost every year (percentage)
+	DefaultLID         = 1.5 //Power lost in the first year to light induced degradation (percentage)
+)
+
+//Number of years forecast when no number is given.
+const DefaultForecastYears = 25
+
+//This is one year of a lifetime forecast.
+type YearOutput struct {
+	Year   int     `json:"year"`   //1 for the first year
+	Output float64 `json:"output"` //Energy produced in the year (kwh)
+	Share  float64 `json:"share"`  //Power left compared to new panels (percentage)
+}
+
+//Projects the yearly output of the panel over years. annual is the yearly
+//output of the new panels before any light induced degradation (kwh). The
+//first year loses the panel's LID, and every year after that loses its
+//degradation rate of the year before.
+func Forecast(annual float64, panel Panel, years int) []YearOutput {
+	forecast := make([]YearOutput, 0, years)
+	share := 1 - panel.LID/100
+	for year := 1; year <= years; year++ {
+		forecast = append(forecast, YearOutput{
+			Year:   year,
+			Output: float64(int(annual*share*100)) / 100,
+			Share:  float64(int(share*10000)) / 100,
+		})
+		share *= 1 - panel.Degradation/100
+	}
+	return forecast
+}
+
+//Gives the energy produced over all of the years of a forecast (kwh).
+func LifetimeOutput(forecast []YearOutput) float64 {
+	var total float64
+	for _, year := range forecast {
+		total += year.Output
+	}
+	return float64(int(total*100)) / 100
+}
@@ -0,0 +1,41 @@
+package solar
+
+import "testing"
+
+func TestForecast(t *testing.T) {
+	tests := []struct {
+		name   string
+		annual float64
+		panel  Panel
+		years  map[int]YearOutput //some years of the forecast
+	}{
+		//Year 1 loses the 2% LID: 10000 * 0.98. Year 2 loses 0.5% of that:
+		//9800 * 0.995. Year 25 has lost it 24 times: 0.98 * 0.995^24 = 0.868920.
+		{"LID and degradation", 10000, Panel{LID: 2, Degradation: 0.5},
+			map[int]YearOutput{1: {1, 9800, 98}, 2: {2, 9751, 97.51}, 25: {25, 8689.2, 86.89}}},
+		//No LID: 0.992^24 = 0.824670 is left in year 25.
+		{"degradation alone", 5000, Panel{Degradation: 0.8},
+			map[int]YearOutput{1: {1, 5000, 100}, 2: {2, 4960, 99.2}, 25: {25, 4123.34, 82.46}}},
+		{"neither", 1000, Panel{},
+			map[int]YearOutput{1: {1, 1000, 100}, 25: {25, 1000, 100}}},
+	}
+	for _, test := range tests {
+		forecast := Forecast(test.annual, test.panel, 25)
+		if len(forecast) != 25 {
+			t.Errorf("%s: got %d years, want 25", test.name, len(forecast))
+			continue
+		}
+		for year, want := range test.years {
+			if forecast[year-1] != want {
+				t.Errorf("%s: got %+v, want %+v", test.name, forecast[year-1], want)
+			}
+		}
+	}
+
+	if lifetime := LifetimeOutput(Forecast(10000, Panel{LID: 2, Degradation: 0.5}, 2)); lifetime != 9800+9751 {
+		t.Errorf("got %g kwh over 2 years, want 19551", lifetime)
+	}
+	if forecast := Forecast(1000, Panel{}, 0); len(forecast) != 0 {
+		t.Errorf("0 years: got %d", len(forecast))
+	}
+}
@@ -0,0 +1,89 @@
+/*Description: This file finds the city closest to a location using the
+great-circle (haversine) distance. Locations use signed WGS84 coordinates
+like a GPS: latitude is negative south of the equator and longitude is
+negative west of Greenwich, so the U.S. has negative longitudes.*/
+
+package solar
+
+import (
+	"fmt"
+	"math"
+)
+
+//Mean radius of the earth (km)
+const EarthRadiusKm = 6371.0
+
+//Miles in one kilometer
+const MilesPerKm = 0.621371
+
+//This is the city closest to a location and how far away it is.
+type Nearest struct {
+	City          string  `json:"city"`
+	DistanceKm    float64 `json:"distanceKm"`
+	DistanceMiles float64 `json:"distanceMiles"`
+}
+
+//TooFarError is returned when the closest city is farther away than the
+//largest distance allowed, so its data would not describe the location.
+type TooFarError struct {
+	Nearest Nearest //The closest city anyway
+	MaxKm   float64 //Largest distance allowed (km)
+}
+
+func (e *TooFarError) Error() string {
+	return fmt.Sprintf("the closest city, %s, is %.0f km away (more than %.0f km)", e.Nearest.City, e.Nearest.DistanceKm, e.MaxKm)
+}
+
+//Gives the latitude of the city (degrees, north is positive).
+func (c City) Latitude() float64 {
+	return c.CoordN
+}
+
+//Gives the longitude of the city (degrees, east is positive). energy.csv
+//stores degrees west, so it is flipped.
+func (c City) Longitude() float64 {
+	return -c.CoordW
+}
+
+//Gives the great-circle distance between two locations (km).
+func Haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := math.Pi / 180
+	dLat := (lat2 - lat1) * toRad
+	dLon := (lon2 - lon1) * toRad
+	a := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+//Finds the city closest to a location. If maxKm is above 0 and the closest
+//city is farther than that, a *TooFarError is returned along with it. A
+//location that isn't a finite number is an error.
+func NearestCity(index *Index, lat, lon, maxKm float64) (Nearest, error) {
+	if !finite(lat) || !finite(lon) {
+		return Nearest{}, fmt.Errorf("the location (%g, %g) is not a number", lat, lon)
+	}
+	found := index.Nearest(lat, lon, 1)
+	if len(found) == 0 {
+		return Nearest{}, fmt.Errorf("there are no cities to search")
+	}
+	nearest := found[0]
+	if !finite(nearest.DistanceKm) {
+		return Nearest{}, fmt.Errorf("the distance to %s can't be worked out", nearest.City)
+	}
+	if maxKm > 0 && nearest.DistanceKm > maxKm {
+		return nearest, &TooFarError{nearest, maxKm}
+	}
+	return nearest, nil
+}
+
+//Finds the name of the city closest to a location, however far away it is.
+//It builds an Index every time, so use NearestCity with the Dataset's Index
+//for more than a few lookups.
+func ClosestCity(cityData map[string]City, lat, lon float64) string {
+	nearest, _ := NearestCity(NewIndex(cityData), lat, lon, 0)
+	return nearest.City
+}
+
+//Reports whether x is a number other than an infinity.
+func finite(x float64) bool {
+	return !math.IsNaN(x) && !math.IsInf(x, 0)
+}
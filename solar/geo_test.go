@@ -0,0 +1,50 @@
+package solar
+
+import (
+	"math"
+	"testing"
+)
+
+//Two cities on either side of the U.S., with degrees west as in energy.csv.
+var testCities = map[string]City{
+	"Boston":  {Name: "Boston", CoordN: 42.3601, CoordW: 71.0589},
+	"Seattle": {Name: "Seattle", CoordN: 47.6062, CoordW: 122.3321},
+}
+
+func TestHaversine(t *testing.T) {
+	//Boston to Seattle is about 4000 km along the great circle.
+	km := Haversine(42.3601, -71.0589, 47.6062, -122.3321)
+	if math.Abs(km-3999) > 10 {
+		t.Errorf("Boston to Seattle: got %.0f km, want about 3999", km)
+	}
+	if km := Haversine(10, 179.5, 10, -179.5); math.Abs(km-109.5) > 1 {
+		t.Errorf("across the date line: got %.1f km, want about 109.5", km)
+	}
+}
+
+func TestNearestCity(t *testing.T) {
+	index := NewIndex(testCities)
+	nearest, err := NearestCity(index, 42, -71, 0)
+	if err != nil || nearest.City != "Boston" {
+		t.Fatalf("got %v, %v; want Boston", nearest, err)
+	}
+	if _, err := NearestCity(index, 0, 0, 500); err == nil {
+		t.Error("a location 7000 km from every city passed a 500 km limit")
+	}
+	tests := []struct {
+		name     string
+		lat, lon float64
+	}{
+		{"NaN latitude", math.NaN(), -71},
+		{"NaN longitude", 42, math.NaN()},
+		{"infinite latitude", math.Inf(1), -71},
+		{"infinite longitude", 42, math.Inf(-1)},
+	}
+	for _, test := range tests {
+		for _, maxKm := range []float64{0, 500} {
+			if nearest, err := NearestCity(index, test.lat, test.lon, maxKm); err == nil {
+				t.Errorf("%s with a limit of %g km: got %s, want an error", test.name, maxKm, nearest.City)
+			}
+		}
+	}
+}
@@ -0,0 +1,192 @@
+/*Description: This file reads Green Button files, the XML export of a
+home's meter readings many utilities offer (the ESPI standard). The file
+is an Atom feed whose entries hold the reading types and the interval
+blocks of readings; the energy readings are added up hour by hour into
+the home's Usage.*/
+
+package solar
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+//ESPI codes of the reading types that are read as energy used.
+const (
+	WattHours   = 72 //Unit of measure: Wh
+	FlowForward = 1  //Flow direction: delivered to the home
+)
+
+//Most years of readings a file can cover.
+const MaxReadingYears = 10
+
+//This is the part of a Green Button feed that is read.
+type espiFeed struct {
+	Entries []espiEntry `xml:"entry"`
+}
+
+//This is one entry of the feed. Its links tie it to the other entries.
+type espiEntry struct {
+	Links   []espiLink  `xml:"link"`
+	Content espiContent `xml:"content"`
+}
+
+//This is an Atom link of an entry.
+type espiLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+//This is what an entry holds; only one of the fields is set in most entries.
+type espiContent struct {
+	ReadingType    *espiReadingType    `xml:"ReadingType"`
+	MeterReading   *struct{}           `xml:"MeterReading"`
+	IntervalBlocks []espiIntervalBlock `xml:"IntervalBlock"`
+	LocalTime      *struct {
+		TzOffset int64 `xml:"tzOffset"` //Seconds from UTC of standard time
+	} `xml:"LocalTimeParameters"`
+}
+
+//This is the unit and direction of the readings of a meter reading.
+type espiReadingType struct {
+	FlowDirection        *int `xml:"flowDirection"`
+	PowerOfTenMultiplier int  `xml:"powerOfTenMultiplier"`
+	Uom                  *int `xml:"uom"`
+}
+
+//This is a block of readings, usually a day of them.
+type espiIntervalBlock struct {
+	Readings []struct {
+		Start    int64   `xml:"timePeriod>start"`    //Unix time
+		Duration int64   `xml:"timePeriod>duration"` //Seconds
+		Value    float64 `xml:"value"`               //In the reading type's unit and power of ten
+	} `xml:"IntervalReading"`
+}
+
+//Gives the href of the entry's link with the relation rel.
+func (e espiEntry) link(rel string) string {
+	for _, link := range e.Links {
+		if link.Rel == rel {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+//Reports whether the readings of the type are energy delivered to the
+//home. A missing unit or flow direction counts as Wh delivered.
+func (rt espiReadingType) energyUsed() bool {
+	return (rt.Uom == nil || *rt.Uom == WattHours) && (rt.FlowDirection == nil || *rt.FlowDirection == FlowForward)
+}
+
+//Reads a Green Button file. Every interval reading of energy delivered to
+//the home is spread over the hours it covers, in the local standard time
+//of the file; readings of other units, or of energy sent to the grid, are
+//left out. When the readings cover an hour of the year more than once
+//(more than a year of readings), the hour gets their average.
+func ReadGreenButton(r io.Reader) (Usage, error) {
+	var feed espiFeed
+	if err := xml.NewDecoder(r).Decode(&feed); err != nil {
+		return Usage{}, fmt.Errorf("not a Green Button file: %v", err)
+	}
+	//The interval blocks link up to their meter reading, which links to
+	//its reading type.
+	readingTypes := make(map[string]espiReadingType)
+	var lastType *espiReadingType
+	meterTypes := make(map[string][]string)
+	var tzOffset int64
+	for _, entry := range feed.Entries {
+		content := entry.Content
+		if content.ReadingType != nil {
+			readingTypes[entry.link("self")] = *content.ReadingType
+			lastType = content.ReadingType
+		}
+		if content.MeterReading != nil {
+			for _, link := range entry.Links {
+				if link.Rel == "related" {
+					meterTypes[entry.link("self")] = append(meterTypes[entry.link("self")], link.Href)
+				}
+			}
+		}
+		if content.LocalTime != nil {
+			tzOffset = content.LocalTime.TzOffset
+		}
+	}
+
+	var energy, covered [HoursPerYear]float64 //kwh and hours read in each hour of the year
+	var first, last int64
+	var read, hours int64
+	for _, entry := range feed.Entries {
+		if len(entry.Content.IntervalBlocks) == 0 {
+			continue
+		}
+		var readingType espiReadingType //Wh delivered when the file has no reading type
+		found := false
+		for _, href := range meterTypes[strings.TrimSuffix(entry.link("up"), "/IntervalBlock")] {
+			if rt, ok := readingTypes[href]; ok {
+				readingType, found = rt, true
+			}
+		}
+		if !found && len(readingTypes) == 1 {
+			readingType = *lastType
+		} else if !found && len(readingTypes) > 1 {
+			return Usage{}, errors.New("an interval block doesn't link to its reading type")
+		}
+		if !readingType.energyUsed() {
+			continue
+		}
+		toKwh := math.Pow(10, float64(readingType.PowerOfTenMultiplier)) / 1000
+		for _, block := range entry.Content.IntervalBlocks {
+			for _, reading := range block.Readings {
+				if reading.Duration <= 0 || reading.Value < 0 {
+					return Usage{}, fmt.Errorf("the reading at %s has a duration of %d seconds and a value of %g",
+						time.Unix(reading.Start, 0).UTC().Format(time.RFC3339), reading.Duration, reading.Value)
+				}
+				hours += reading.Duration/3600 + 1
+				if hours > MaxReadingYears*HoursPerYear {
+					return Usage{}, fmt.Errorf("the readings cover more than %d years", MaxReadingYears)
+				}
+				kwh := reading.Value * toKwh
+				start := reading.Start + tzOffset //local seconds
+				end := start + reading.Duration
+				for t := start; t < end; {
+					next := (t/3600 + 1) * 3600 //the start of the next hour
+					if next > end {
+						next = end
+					}
+					h := hourOfYear(time.Unix(t, 0).UTC())
+					energy[h] += kwh * float64(next-t) / float64(reading.Duration)
+					covered[h] += float64(next-t) / 3600
+					t = next
+				}
+				if read == 0 || start < first {
+					first = start
+				}
+				if read == 0 || end > last {
+					last = end
+				}
+				read++
+			}
+		}
+	}
+	if read == 0 {
+		return Usage{}, errors.New("the file has no readings of energy used (Wh)")
+	}
+
+	hourly := make(Readings, HoursPerYear)
+	for h := range hourly {
+		hourly[h] = math.NaN()
+		if covered[h] > 0 {
+			hourly[h] = float64(int(energy[h]/covered[h]*1000)) / 1000 //to the wh
+		}
+	}
+	usage, err := NewUsage(hourly)
+	usage.From = time.Unix(first, 0).UTC().Format(DateLayout)
+	usage.To = time.Unix(last-1, 0).UTC().Format(DateLayout)
+	return usage, err
+}
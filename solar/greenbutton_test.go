@@ -0,0 +1,185 @@
+package solar
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+//Makes a Green Button feed of entries, with the time zone tzOffset
+//seconds from UTC.
+func greenButton(tzOffset int64, entries ...string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:espi="http://naesb.org/espi">
+<entry><link rel="self" href="/LocalTimeParameters/1"/><content><espi:LocalTimeParameters><espi:tzOffset>%d</espi:tzOffset></espi:LocalTimeParameters></content></entry>
+%s
+</feed>`, tzOffset, strings.Join(entries, "\n"))
+}
+
+//Makes the entries of meter reading id and its reading type.
+func meterReading(id, flow, uom, powerOfTen int) string {
+	return fmt.Sprintf(`<entry><link rel="self" href="/UsagePoint/1/MeterReading/%[1]d"/><link rel="related" href="/ReadingType/%[1]d"/><link rel="related" href="/UsagePoint/1/MeterReading/%[1]d/IntervalBlock"/><content><espi:MeterReading/></content></entry>
+<entry><link rel="self" href="/ReadingType/%[1]d"/><content><espi:ReadingType><espi:flowDirection>%[2]d</espi:flowDirection><espi:powerOfTenMultiplier>%[4]d</espi:powerOfTenMultiplier><espi:uom>%[3]d</espi:uom></espi:ReadingType></content></entry>`,
+		id, flow, uom, powerOfTen)
+}
+
+//This is an interval reading: start is UTC, duration in seconds.
+type testReading struct {
+	start    string
+	duration int64
+	value    float64
+}
+
+//Makes an interval block entry of meter reading id.
+func intervalBlock(id int, readings ...testReading) string {
+	var xml strings.Builder
+	for _, r := range readings {
+		start, err := time.Parse(time.RFC3339, r.start)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Fprintf(&xml, `<espi:IntervalReading><espi:timePeriod><espi:duration>%d</espi:duration><espi:start>%d</espi:start></espi:timePeriod><espi:value>%g</espi:value></espi:IntervalReading>`,
+			r.duration, start.Unix(), r.value)
+	}
+	return fmt.Sprintf(`<entry><link rel="up" href="/UsagePoint/1/MeterReading/%d/IntervalBlock"/><content><espi:IntervalBlock>%s</espi:IntervalBlock></content></entry>`,
+		id, xml.String())
+}
+
+func TestReadGreenButton(t *testing.T) {
+	const est = -5 * 3600
+	tests := []struct {
+		name     string
+		feed     string
+		hourly   map[int]float64 //the hours that were read, the others must be null
+		from, to string
+	}{
+		//Midnight and 1:00 in New York on 1 January.
+		{"hourly readings", greenButton(est, meterReading(1, FlowForward, WattHours, 0),
+			intervalBlock(1, testReading{"2023-01-01T05:00:00Z", 3600, 1500}, testReading{"2023-01-01T06:00:00Z", 3600, 2000})),
+			map[int]float64{0: 1.5, 1: 2}, "2023-01-01", "2023-01-01"},
+		{"kwh", greenButton(0, meterReading(1, FlowForward, WattHours, 3),
+			intervalBlock(1, testReading{"2023-03-01T12:00:00Z", 3600, 1.25})),
+			map[int]float64{59*24 + 12: 1.25}, "2023-03-01", "2023-03-01"},
+		{"quarter hours", greenButton(0, meterReading(1, FlowForward, WattHours, 0),
+			intervalBlock(1, testReading{"2023-01-01T00:00:00Z", 900, 250}, testReading{"2023-01-01T00:15:00Z", 900, 250},
+				testReading{"2023-01-01T00:30:00Z", 900, 500}, testReading{"2023-01-01T00:45:00Z", 900, 1000})),
+			map[int]float64{0: 2}, "2023-01-01", "2023-01-01"},
+		//4 kwh from 0:30 to 2:30: each hour is at the reading's 2 kwh an hour.
+		{"a reading over three hours", greenButton(0, meterReading(1, FlowForward, WattHours, 0),
+			intervalBlock(1, testReading{"2023-01-01T00:30:00Z", 7200, 4000})),
+			map[int]float64{0: 2, 1: 2, 2: 2}, "2023-01-01", "2023-01-01"},
+		{"energy sent to the grid and watts left out", greenButton(0,
+			meterReading(1, 19, WattHours, 0), meterReading(2, FlowForward, 38, 0), meterReading(3, FlowForward, WattHours, 0),
+			intervalBlock(1, testReading{"2023-01-01T00:00:00Z", 3600, 9000}),
+			intervalBlock(2, testReading{"2023-01-01T00:00:00Z", 3600, 7000}),
+			intervalBlock(3, testReading{"2023-01-01T00:00:00Z", 3600, 1000})),
+			map[int]float64{0: 1}, "2023-01-01", "2023-01-01"},
+		{"no reading type", greenButton(0, intervalBlock(1, testReading{"2023-01-01T00:00:00Z", 3600, 1000})),
+			map[int]float64{0: 1}, "2023-01-01", "2023-01-01"},
+		//29 February is counted as 28 February.
+		{"leap day", greenButton(0, meterReading(1, FlowForward, WattHours, 0),
+			intervalBlock(1, testReading{"2024-02-28T10:00:00Z", 3600, 1000}, testReading{"2024-02-29T10:00:00Z", 3600, 3000})),
+			map[int]float64{58*24 + 10: 2}, "2024-02-28", "2024-02-29"},
+		{"two years", greenButton(0, meterReading(1, FlowForward, WattHours, 0),
+			intervalBlock(1, testReading{"2024-01-01T00:00:00Z", 3600, 3000}, testReading{"2023-01-01T00:00:00Z", 3600, 1000})),
+			map[int]float64{0: 2}, "2023-01-01", "2024-01-01"},
+	}
+	for _, test := range tests {
+		usage, err := ReadGreenButton(strings.NewReader(test.feed))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if usage.Source != MeterReadings || usage.Hours != len(test.hourly) || usage.From != test.from || usage.To != test.to {
+			t.Errorf("%s: got %s readings of %d hours from %s to %s, want %d hours from %s to %s",
+				test.name, usage.Source, usage.Hours, usage.From, usage.To, len(test.hourly), test.from, test.to)
+		}
+		for h, kwh := range usage.Hourly {
+			want, read := test.hourly[h]
+			if read && math.Abs(kwh-want) > 1e-9 || !read && !math.IsNaN(kwh) {
+				t.Errorf("%s: hour %d is %g kwh, want %g", test.name, h, kwh, want)
+			}
+		}
+	}
+
+	errorTests := []struct {
+		name, feed, err string
+	}{
+		{"not xml", "hour,kwh\n0,1.5\n", "not a Green Button file"},
+		{"only energy sent to the grid", greenButton(0, meterReading(1, 19, WattHours, 0),
+			intervalBlock(1, testReading{"2023-01-01T00:00:00Z", 3600, 1000})), "no readings of energy used"},
+		{"negative reading", greenButton(0, meterReading(1, FlowForward, WattHours, 0),
+			intervalBlock(1, testReading{"2023-01-01T00:00:00Z", 3600, -1})), "the reading at 2023-01-01T00:00:00Z"},
+		{"no duration", greenButton(0, meterReading(1, FlowForward, WattHours, 0),
+			intervalBlock(1, testReading{"2023-01-01T00:00:00Z", 0, 1000})), "a duration of 0 seconds"},
+		{"block of an unknown meter", greenButton(0, meterReading(1, FlowForward, WattHours, 0), meterReading(2, 19, WattHours, 0),
+			intervalBlock(3, testReading{"2023-01-01T00:00:00Z", 3600, 1000})), "doesn't link to its reading type"},
+		{"too many years", greenButton(0, meterReading(1, FlowForward, WattHours, 0),
+			intervalBlock(1, testReading{"2013-01-01T00:00:00Z", 11 * 365 * 24 * 3600, 1000})), "more than 10 years"},
+	}
+	for _, test := range errorTests {
+		_, err := ReadGreenButton(strings.NewReader(test.feed))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got %v, want an error with %q", test.name, err, test.err)
+		}
+	}
+}
+
+//Makes HoursPerYear readings with kwh in the hours listed and null in the others.
+func someHours(kwh map[int]float64) Readings {
+	hourly := make(Readings, HoursPerYear)
+	for h := range hourly {
+		hourly[h] = math.NaN()
+		if value, ok := kwh[h]; ok {
+			hourly[h] = value
+		}
+	}
+	return hourly
+}
+
+func TestNewUsage(t *testing.T) {
+	//Two hours of January read, at 2 kwh on average: 1488 kwh in its 744 hours.
+	usage, err := NewUsage(someHours(map[int]float64{0: 1, 5: 3}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Hours != 2 || usage.Monthly[0] != 1488 || !math.IsNaN(usage.Monthly[1]) {
+		t.Errorf("got %d hours and %g kwh in January, %g in February; want 2, 1488 and null",
+			usage.Hours, usage.Monthly[0], usage.Monthly[1])
+	}
+	tests := []struct {
+		name   string
+		hourly Readings
+		err    string
+	}{
+		{"a day", make(Readings, 24), "expected 8760 hourly values, found 24"},
+		{"negative", someHours(map[int]float64{3: -1}), "hour 3"},
+		{"infinite", someHours(map[int]float64{4: math.Inf(1)}), "hour 4"},
+		{"nothing read", someHours(nil), "no hour of the year was read"},
+	}
+	for _, test := range tests {
+		if _, err := NewUsage(test.hourly); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got %v, want an error with %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestUsageProfile(t *testing.T) {
+	baseline := make([]float64, HoursPerYear)
+	for h := range baseline {
+		baseline[h] = 2
+	}
+	//The home used 3 kwh where the baseline has 2, so the hours that
+	//weren't read get 1.5 times the baseline.
+	usage := Usage{Hourly: someHours(map[int]float64{0: 3, 1: 0, 2: 6})}
+	load := usage.Profile(baseline)
+	if load[0] != 3 || load[1] != 0 || load[2] != 6 || load[3] != 3 || load[HoursPerYear-1] != 3 {
+		t.Errorf("got %g, %g, %g, %g and %g kwh in the last hour; want 3, 0, 6, 3 and 3",
+			load[0], load[1], load[2], load[3], load[HoursPerYear-1])
+	}
+	if load := (Usage{Hourly: someHours(map[int]float64{0: 1})}).Profile(make([]float64, HoursPerYear)); load[0] != 1 || load[1] != 0 {
+		t.Errorf("a baseline of 0: got %g and %g, want 1 and 0", load[0], load[1])
+	}
+}
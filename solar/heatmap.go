@@ -0,0 +1,87 @@
+/*Description: This file holds the heat map calculations, which color code
+every city by how much of a house's energy solar power would cover.*/
+
+package solar
+
+//Makes a map of color markers for each city based on chosen house size and difference in output
+func MakeColorMarkers(cityData map[string]City, houseSize, roofSize float64, panel Panel, losses Losses) map[string]string {
+	var output, avgEnergy float64
+	colors := make(map[string]string)
+	for cityName, city := range cityData {
+		output = HeatMapOutput(city, roofSize, panel, losses)
+		output = float64(int(output*100)) / 100
+		avgEnergy = AverageEnergy(city) * houseSize
+		avgEnergy = float64(int(avgEnergy*100)) / 100
+		colors[cityName] = MapColor(avgEnergy, output)
+	}
+	return colors
+}
+
+//Gives the expected output of the panel on a flat roof in the city, derated
+//for the city's temperature. (in kwh per month)
+func HeatMapOutput(city City, roofSize float64, panel Panel, losses Losses) float64 {
+	factor := TemperatureFactor(city, panel, city.SolarRad)
+	return SolarOutput(city, "horizontal", panel.Efficiency, roofSize, losses) * factor
+}
+
+//Computes difference in energy and chooses color
+func MapColor(avgEnergy, energyOutput float64) string {
+	percentage := energyOutput / avgEnergy
+	var color string
+	if percentage >= .8 {
+		color = "green"
+	} else if percentage > 0.6 && percentage < 0.8 {
+		color = "yellow"
+	} else {
+		color = "red"
+	}
+	return color
+}
+
+//Makes list of cities that have a certain color code
+func MakeList(colors map[string]string, color string) []string {
+	cityList := make([]string, 0)
+	for cityName, mapColor := range colors {
+		if mapColor == color {
+			cityList = append(cityList, cityName)
+		}
+	}
+	return cityList
+}
+
+//Computes the percentage of the cities that are defined as a certain color,
+//0 when there are no cities
+func ColorPercent(colors map[string]string, color string) float64 {
+	if len(colors) == 0 {
+		return 0
+	}
+	var colorCount int
+	for _, mapColor := range colors {
+		if mapColor == color {
+			colorCount++
+		}
+	}
+	return float64(int((float64(colorCount)/float64(len(colors)))*1000)) / 10
+}
+
+//Make an array of colors in the order of cityNames
+func MakeColors(cityNames []string, cityData map[string]City, houseSize, roofSize float64, panel Panel, losses Losses) []string {
+	var output, avgEnergy float64
+	var mapColor string
+	colors := make([]string, 0)
+	for _, cityName := range cityNames {
+		city := cityData[cityName]
+		output = HeatMapOutput(city, roofSize, panel, losses)
+		avgEnergy = AverageEnergy(city) * houseSize
+		mapColor = MapColor(avgEnergy, output)
+		if mapColor == "red" {
+			mapColor = "#FF0000"
+		} else if mapColor == "yellow" {
+			mapColor = "#FFFF00"
+		} else {
+			mapColor = "#008000"
+		}
+		colors = append(colors, mapColor)
+	}
+	return colors
+}
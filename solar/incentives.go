@@ -0,0 +1,209 @@
+/*Description: This file holds the incentives catalog: the tax credits,
+rebates and payments for solar energy (such as SRECs) that lower what
+panels cost. The catalog is a JSON file. Every incentive says where it is
+offered, which systems qualify and when, and is applied to the cost of
+every panel option.*/
+
+package solar
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"time"
+)
+
+//Kinds of incentives, which say what Amount means.
+const (
+	PercentOfCost = "percent" //Percentage of the cost left after the incentives before it in the catalog
+	PerWatt       = "perWatt" //Dollars per watt of panels
+	FixedAmount   = "fixed"   //Dollars
+	PerKwh        = "perKwh"  //Dollars per kwh the panels make, paid for Years years
+)
+
+//Layout of the dates of the catalog.
+const DateLayout = "2006-01-02"
+
+//This is one incentive of the catalog.
+type Incentive struct {
+	ID      string   `json:"id"`      //Short name, unique in the catalog
+	Name    string   `json:"name"`    //Name shown to the user
+	States  []string `json:"states"`  //Two letter codes of the states it is offered in, every state if empty
+	Cities  []string `json:"cities"`  //Cities of the city data it is offered in, every city of its states if empty
+	Type    string   `json:"type"`    //PercentOfCost, PerWatt, FixedAmount or PerKwh
+	Amount  float64  `json:"amount"`  //Percentage, dollars per watt, dollars or dollars per kwh, by Type
+	Years   int      `json:"years"`   //Years a PerKwh incentive is paid
+	Cap     float64  `json:"cap"`     //Most it pays in all (dollars), 0 for no cap
+	MaxKw   float64  `json:"maxKw"`   //Largest system that qualifies (kw), 0 for any size
+	Start   string   `json:"start"`   //First installation day that qualifies (YYYY-MM-DD), empty if there is none
+	Expires string   `json:"expires"` //Last installation day that qualifies (YYYY-MM-DD), empty if it doesn't expire
+}
+
+//This is an incentive offered where a panel option would be installed and
+//what it is worth to that option.
+type AppliedIncentive struct {
+	ID     string  `json:"id"`               //ID of the incentive in the catalog
+	Name   string  `json:"name"`             //Name of the incentive
+	Amount float64 `json:"amount"`           //What it pays (dollars); for PerKwh incentives, over all of its years
+	PerKwh float64 `json:"perKwh,omitempty"` //Payment for every kwh (dollars per kwh), for incentives paid with the output
+	Years  int     `json:"years,omitempty"`  //Years the payments for every kwh last
+	Reason string  `json:"reason,omitempty"` //Why the option doesn't qualify, empty if it does
+
+	cap float64 //Most a PerKwh incentive pays in all (dollars), 0 for no cap
+}
+
+//Reads and checks the incentives catalog, a JSON list of incentives. A
+//missing file is an empty catalog.
+func ReadIncentives(filename string) ([]Incentive, error) {
+	if filename == "" {
+		return nil, nil
+	}
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var catalog []Incentive
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields() //a misspelled field would otherwise be an incentive without limits
+	if err := decoder.Decode(&catalog); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	ids := make(map[string]bool)
+	for i, incentive := range catalog {
+		if err := incentive.check(); err != nil {
+			return nil, fmt.Errorf("%s: incentive %d (%s): %v", filename, i+1, incentive.ID, err)
+		}
+		if ids[incentive.ID] {
+			return nil, fmt.Errorf("%s: incentive %d: id %q is listed twice", filename, i+1, incentive.ID)
+		}
+		ids[incentive.ID] = true
+	}
+	return catalog, nil
+}
+
+//Checks that the incentive can be applied.
+func (in Incentive) check() error {
+	if in.ID == "" || in.Name == "" {
+		return errors.New("missing id or name")
+	}
+	switch in.Type {
+	case PercentOfCost:
+		if in.Amount > 100 {
+			return errors.New("a percentage can't be more than 100")
+		}
+	case PerKwh:
+		if in.Years <= 0 {
+			return errors.New("a perKwh incentive must be paid for a number of years")
+		}
+	case PerWatt, FixedAmount:
+	default:
+		return fmt.Errorf("type must be %q, %q, %q or %q, not %q", PercentOfCost, PerWatt, FixedAmount, PerKwh, in.Type)
+	}
+	if in.Amount < 0 || in.Cap < 0 || in.MaxKw < 0 {
+		return errors.New("amount, cap and maxKw can't be negative")
+	}
+	for _, state := range in.States {
+		if len(state) != 2 {
+			return fmt.Errorf("state %q must be a two letter code", state)
+		}
+	}
+	for _, date := range []string{in.Start, in.Expires} {
+		if _, err := time.Parse(DateLayout, date); date != "" && err != nil {
+			return fmt.Errorf("date %q must be written as YYYY-MM-DD", date)
+		}
+	}
+	if in.Start != "" && in.Expires != "" && in.Expires < in.Start {
+		return errors.New("expires before it starts")
+	}
+	return nil
+}
+
+//Reports whether the incentive is offered in the city.
+func (in Incentive) Offered(city City) bool {
+	return (len(in.States) == 0 || contains(in.States, city.State)) &&
+		(len(in.Cities) == 0 || contains(in.Cities, city.Name))
+}
+
+//Gives why a system of kw kilowatts installed on date doesn't qualify for
+//the incentive, or "" if it does.
+func (in Incentive) Ineligible(kw float64, date time.Time) string {
+	day := date.Format(DateLayout) //dates written this way sort like the days
+	switch {
+	case in.Start != "" && day < in.Start:
+		return "starts on " + in.Start
+	case in.Expires != "" && day > in.Expires:
+		return "ended on " + in.Expires
+	case in.MaxKw > 0 && kw > in.MaxKw:
+		return fmt.Sprintf("only for systems up to %g kw", in.MaxKw)
+	}
+	return ""
+}
+
+//Reports whether list holds s.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+//Applies the incentives of the catalog offered in the city to every panel
+//option installed on date, like CalcCostBrand gives them. The system size
+//is the Kw of the panels bought. The incentives paid up front come off the
+//cost in the order of the catalog, giving NetCost; the ones paid for every
+//kwh are worked out with the forecast by AnalyzeOptions.
+func ApplyIncentives(options []PanelOption, city City, catalog []Incentive, date time.Time) {
+	for i := range options {
+		kw := options[i].Kw
+		left := float64(options[i].Cost)
+		options[i].Incentives = nil
+		for _, incentive := range catalog {
+			if !incentive.Offered(city) {
+				continue
+			}
+			applied := AppliedIncentive{ID: incentive.ID, Name: incentive.Name, Reason: incentive.Ineligible(kw, date)}
+			if applied.Reason == "" {
+				switch incentive.Type {
+				case PercentOfCost:
+					applied.Amount = left * incentive.Amount / 100
+				case PerWatt:
+					applied.Amount = kw * 1000 * incentive.Amount
+				case FixedAmount:
+					applied.Amount = incentive.Amount
+				case PerKwh:
+					applied.PerKwh, applied.Years, applied.cap = incentive.Amount, incentive.Years, incentive.Cap
+				}
+				if incentive.Cap > 0 {
+					applied.Amount = math.Min(applied.Amount, incentive.Cap)
+				}
+				applied.Amount = math.Min(applied.Amount, left) //never more than is left to pay
+				applied.Amount = float64(int(applied.Amount*100)) / 100
+				left -= applied.Amount
+			}
+			options[i].Incentives = append(options[i].Incentives, applied)
+		}
+		options[i].NetCost = int(left)
+	}
+}
+
+//Adds the payments of a PerKwh incentive in each year of the forecast to
+//income (dollars) and gives what it pays in all.
+func (a AppliedIncentive) addIncome(forecast []YearOutput, income []float64) float64 {
+	var total float64
+	for y := 0; y < a.Years && y < len(forecast); y++ {
+		payment := forecast[y].Output * a.PerKwh
+		if a.cap > 0 {
+			payment = math.Min(payment, a.cap-total)
+		}
+		income[y] += payment
+		total += payment
+	}
+	return float64(int(total*100)) / 100
+}
@@ -0,0 +1,144 @@
+package solar
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyIncentives(t *testing.T) {
+	austin := City{Name: "Austin", State: "TX"}
+	catalog := []Incentive{
+		{ID: "federal", Name: "30% of the cost", Type: PercentOfCost, Amount: 30},
+		{ID: "state", Name: "25% of what is left, at most 1000", States: []string{"TX"}, Type: PercentOfCost, Amount: 25, Cap: 1000},
+		{ID: "watts", Name: "0.25 a watt, at most 3000", Type: PerWatt, Amount: 0.25, Cap: 3000},
+		{ID: "city", Name: "2500 up to 20 kw", Cities: []string{"Austin"}, Type: FixedAmount, Amount: 2500, MaxKw: 20},
+		{ID: "small", Name: "500 up to 5 kw", Type: FixedAmount, Amount: 500, MaxKw: 5},
+		{ID: "elsewhere", Name: "another state", States: []string{"MA"}, Type: FixedAmount, Amount: 1000},
+		{ID: "expired", Name: "ended", Type: FixedAmount, Amount: 1000, Expires: "2024-12-31"},
+		{ID: "later", Name: "not started", Type: FixedAmount, Amount: 1000, Start: "2026-01-01"},
+		{ID: "srec", Name: "0.085 a kwh for 15 years", Type: PerKwh, Amount: 0.085, Years: 15, Cap: 4000},
+		{ID: "huge", Name: "more than is left", Type: FixedAmount, Amount: 100000},
+	}
+	options := []PanelOption{{Name: "Twenty", Kw: 10, Cost: 30000}}
+	ApplyIncentives(options, austin, catalog, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC))
+
+	want := []AppliedIncentive{
+		{ID: "federal", Amount: 9000}, //30% of 30000
+		{ID: "state", Amount: 1000},   //25% of 21000 is more than the cap
+		{ID: "watts", Amount: 2500},   //10000 watts
+		{ID: "city", Amount: 2500},    //10 kw is small enough
+		{ID: "small", Reason: "only for systems up to 5 kw"},
+		{ID: "expired", Reason: "ended on 2024-12-31"},
+		{ID: "later", Reason: "starts on 2026-01-01"},
+		{ID: "srec", PerKwh: 0.085, Years: 15}, //paid with the output, see AnalyzeOptions
+		{ID: "huge", Amount: 15000},            //the 15000 left to pay
+	}
+	got := options[0].Incentives
+	if len(got) != len(want) {
+		t.Fatalf("got %d incentives, want %d (the one of another state left out): %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i].ID != want[i].ID || math.Abs(got[i].Amount-want[i].Amount) > 0.011 || got[i].Reason != want[i].Reason ||
+			got[i].PerKwh != want[i].PerKwh || got[i].Years != want[i].Years {
+			t.Errorf("incentive %d: got %+v, want %+v", i+1, got[i], want[i])
+		}
+	}
+	if options[0].NetCost != 0 {
+		t.Errorf("got a net cost of %d, want 0", options[0].NetCost)
+	}
+
+	//The same catalog a year later, in another state.
+	options = []PanelOption{{Name: "Twenty", Kw: 10, Cost: 30000}}
+	boston := City{Name: "Boston", State: "MA"}
+	ApplyIncentives(options, boston, catalog[:6], time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC))
+	var total float64
+	for _, applied := range options[0].Incentives {
+		total += applied.Amount
+	}
+	//30% of 30000, 2500 for the watts and 1000 for Massachusetts.
+	if total != 12500 || options[0].NetCost != 17500 {
+		t.Errorf("Boston: got %g of incentives and a net cost of %d, want 12500 and 17500", total, options[0].NetCost)
+	}
+}
+
+func TestApplyIncentivesToPanelsBought(t *testing.T) {
+	//600 kwh a month take 9 of the 46 panels that fit on the roof: 3.6 kw
+	//of the roof's 18.4.
+	panels := []Panel{{Name: "Twenty", Efficiency: 20, Area: 2, Price: 300}}
+	options := CalcCostBrand(600, 1000, City{SolarRad: 5, InstCost: 1}, Orientation{}, Losses{InverterEfficiency: 100}, panels)
+	catalog := []Incentive{
+		{ID: "watts", Name: "0.25 a watt", Type: PerWatt, Amount: 0.25},
+		{ID: "small", Name: "500 up to 5 kw", Type: FixedAmount, Amount: 500, MaxKw: 5},
+	}
+	ApplyIncentives(options, City{}, catalog, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC))
+	got := options[0].Incentives
+	if options[0].Kw != 3.6 || got[0].Amount != 900 || got[1].Amount != 500 || got[1].Reason != "" {
+		t.Errorf("%g kw: got %+v, want 900 for the watts and 500 for a small system", options[0].Kw, got)
+	}
+	//7700 for 9 panels and installing them, less 1400.
+	if options[0].NetCost != 6300 {
+		t.Errorf("got a net cost of %d, want 6300", options[0].NetCost)
+	}
+}
+
+func TestAddIncome(t *testing.T) {
+	forecast := flatForecast(1000, 4)
+	tests := []struct {
+		name    string
+		applied AppliedIncentive
+		income  []float64
+		total   float64
+	}{
+		{"paid for 3 years", AppliedIncentive{PerKwh: 0.1, Years: 3}, []float64{100, 100, 100, 0}, 300},
+		{"capped", AppliedIncentive{PerKwh: 0.1, Years: 3, cap: 250}, []float64{100, 100, 50, 0}, 250},
+		{"longer than the forecast", AppliedIncentive{PerKwh: 0.1, Years: 15}, []float64{100, 100, 100, 100}, 400},
+	}
+	for _, test := range tests {
+		income := make([]float64, len(forecast))
+		total := test.applied.addIncome(forecast, income)
+		if total != test.total {
+			t.Errorf("%s: got %g in all, want %g", test.name, total, test.total)
+		}
+		for y := range income {
+			if math.Abs(income[y]-test.income[y]) > 1e-9 {
+				t.Errorf("%s: year %d pays %g, want %g", test.name, y+1, income[y], test.income[y])
+			}
+		}
+	}
+}
+
+func TestReadIncentives(t *testing.T) {
+	catalog, err := ReadIncentives("testdata/incentives.example.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(catalog) != 5 || catalog[0].ID != "example-austin-rebate" {
+		t.Errorf("got %d incentives, want the 5 examples", len(catalog))
+	}
+	if catalog, err := ReadIncentives("testdata/missing.json"); err != nil || catalog != nil {
+		t.Errorf("a missing file: got %v, %v", catalog, err)
+	}
+
+	tests := []struct {
+		name, json, err string
+	}{
+		{"no id", `[{"name": "a", "type": "fixed", "amount": 1}]`, "missing id or name"},
+		{"unknown type", `[{"id": "a", "name": "a", "type": "loan", "amount": 1}]`, "type must be"},
+		{"more than 100%", `[{"id": "a", "name": "a", "type": "percent", "amount": 101}]`, "more than 100"},
+		{"perKwh without years", `[{"id": "a", "name": "a", "type": "perKwh", "amount": 0.1}]`, "number of years"},
+		{"negative cap", `[{"id": "a", "name": "a", "type": "fixed", "amount": 1, "cap": -1}]`, "can't be negative"},
+		{"state name", `[{"id": "a", "name": "a", "type": "fixed", "amount": 1, "states": ["Texas"]}]`, "two letter code"},
+		{"date", `[{"id": "a", "name": "a", "type": "fixed", "amount": 1, "expires": "12/31/2025"}]`, "YYYY-MM-DD"},
+		{"expires before it starts", `[{"id": "a", "name": "a", "type": "fixed", "amount": 1, "start": "2025-01-01", "expires": "2024-01-01"}]`, "expires before it starts"},
+		{"listed twice", `[{"id": "a", "name": "a", "type": "fixed", "amount": 1}, {"id": "a", "name": "b", "type": "fixed", "amount": 2}]`, "listed twice"},
+		{"misspelled field", `[{"id": "a", "name": "a", "type": "fixed", "amount": 1, "maxPower": 10}]`, "unknown field"},
+	}
+	for _, test := range tests {
+		_, err := ReadIncentives(writeFile(t, "incentives.json", test.json))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got %v, want an error with %q", test.name, err, test.err)
+		}
+	}
+}
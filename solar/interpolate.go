@@ -0,0 +1,70 @@
+/*Description: This file blends the data of several nearby cities into one
+City for a location, so that two houses a short distance apart get similar
+estimates instead of jumping when the closest city changes.*/
+
+package solar
+
+import "math"
+
+//Power of the distance used by inverse distance weighting. With 2, a city
+//twice as far away counts a quarter as much.
+const IDWPower = 2.0
+
+//This is one city that contributed to an interpolated estimate.
+type StationWeight struct {
+	City       string  `json:"city"`
+	DistanceKm float64 `json:"distanceKm"`
+	Weight     float64 `json:"weight"` //Share of the blend, the weights add up to 1
+}
+
+//Blends the k closest cities with inverse distance weighting. The solar
+//radiation (yearly and monthly), optimal angle, temperature, energy usage and installation cost
+//are weighted averages; the name and companies are the closest city's. If
+//the location is right on a city, that city gets all of the weight.
+func Interpolate(index *Index, lat, lon float64, k int) (City, []StationWeight) {
+	nearest := index.Nearest(lat, lon, k)
+	if len(nearest) == 0 {
+		return City{}, nil
+	}
+	weights := make([]StationWeight, len(nearest))
+	var total float64
+	for i, n := range nearest {
+		var weight float64
+		if nearest[0].DistanceKm < 0.001 {
+			if i == 0 {
+				weight = 1 //right on the closest city
+			}
+		} else {
+			weight = 1 / math.Pow(n.DistanceKm, IDWPower)
+		}
+		weights[i] = StationWeight{n.City, n.DistanceKm, weight}
+		total += weight
+	}
+
+	closest := index.city(nearest[0].City)
+	blend := City{
+		Name:      closest.Name,
+		CoordN:    lat,
+		CoordW:    -lon,
+		Companies: closest.Companies,
+		State:     closest.State,
+	}
+	var monthly [12]float64
+	for i := range weights {
+		weights[i].Weight /= total
+		city := index.city(weights[i].City)
+		w := weights[i].Weight
+		blend.Temp += w * city.Temp
+		blend.SolarRad += w * city.SolarRad
+		blend.OptAng += w * city.OptAng
+		blend.OptRad += w * city.OptRad
+		blend.AvgEnergy += w * city.AvgEnergy
+		blend.InstCost += w * city.InstCost
+		cityMonthly := city.Monthly()
+		for m := range monthly {
+			monthly[m] += w * cityMonthly[m]
+		}
+	}
+	blend.MonthlyRad = &monthly
+	return blend, weights
+}
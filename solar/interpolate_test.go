@@ -0,0 +1,83 @@
+package solar
+
+import (
+	"math"
+	"testing"
+)
+
+//Two cities on the equator 3 degrees apart and one far away. Along the
+//equator the distances are in the same ratio as the degrees.
+func equatorCities() map[string]City {
+	flat := [12]float64{4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4}
+	return map[string]City{
+		"West": {Name: "West", CoordN: 0, CoordW: 0, SolarRad: 4, OptRad: 4.5, Temp: 20, AvgEnergy: 600, InstCost: 3, MonthlyRad: &flat},
+		"East": {Name: "East", CoordN: 0, CoordW: -3, SolarRad: 6, OptRad: 7, Temp: 30, AvgEnergy: 900, InstCost: 4},
+		"Far":  {Name: "Far", CoordN: 40, CoordW: -60, SolarRad: 2, OptRad: 2, Temp: 0, AvgEnergy: 300, InstCost: 5},
+	}
+}
+
+func TestInterpolateWeights(t *testing.T) {
+	index := NewIndex(equatorCities())
+	tests := []struct {
+		name     string
+		lat, lon float64
+		k        int
+		weights  map[string]float64
+	}{
+		//1/1² against 1/2²: 4 to 1.
+		{"a third of the way", 0, 1, 2, map[string]float64{"West": 0.8, "East": 0.2}},
+		{"half way", 0, 1.5, 2, map[string]float64{"West": 0.5, "East": 0.5}},
+		{"on a city", 0, 3, 3, map[string]float64{"East": 1, "West": 0, "Far": 0}},
+		{"one city", 0, 1, 1, map[string]float64{"West": 1}},
+	}
+	for _, test := range tests {
+		_, stations := Interpolate(index, test.lat, test.lon, test.k)
+		if len(stations) != len(test.weights) {
+			t.Errorf("%s: got %d stations, want %d", test.name, len(stations), len(test.weights))
+			continue
+		}
+		var total float64
+		for _, s := range stations {
+			total += s.Weight
+			if want, ok := test.weights[s.City]; !ok || math.Abs(s.Weight-want) > 1e-9 {
+				t.Errorf("%s: %s has a weight of %g, want %g", test.name, s.City, s.Weight, want)
+			}
+		}
+		if math.Abs(total-1) > 1e-9 {
+			t.Errorf("%s: the weights add up to %g", test.name, total)
+		}
+	}
+}
+
+func TestInterpolateBlend(t *testing.T) {
+	index := NewIndex(equatorCities())
+	city, _ := Interpolate(index, 0, 1, 2) //West 0.8, East 0.2
+	want := City{SolarRad: 4.4, OptRad: 5, Temp: 22, AvgEnergy: 660, InstCost: 3.2}
+	for _, field := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"SolarRad", city.SolarRad, want.SolarRad},
+		{"OptRad", city.OptRad, want.OptRad},
+		{"Temp", city.Temp, want.Temp},
+		{"AvgEnergy", city.AvgEnergy, want.AvgEnergy},
+		{"InstCost", city.InstCost, want.InstCost},
+	} {
+		if math.Abs(field.got-field.want) > 1e-9 {
+			t.Errorf("%s: got %g, want %g", field.name, field.got, field.want)
+		}
+	}
+	if city.Name != "West" || city.Latitude() != 0 || city.Longitude() != 1 {
+		t.Errorf("got %s at %g, %g; want West at the location", city.Name, city.Latitude(), city.Longitude())
+	}
+	//West's measured months are blended with East's worked out ones.
+	east := SynthesizeMonthly(index.city("East"))
+	for m, value := range city.Monthly() {
+		if want := 0.8*4 + 0.2*east[m]; math.Abs(value-want) > 1e-9 {
+			t.Errorf("month %d: got %g, want %g", m+1, value, want)
+		}
+	}
+	if city, stations := Interpolate(NewIndex(nil), 0, 0, 4); city.Name != "" || stations != nil {
+		t.Errorf("an empty index gave %v, %v", city, stations)
+	}
+}
@@ -0,0 +1,219 @@
+/*Description: This file is a spatial index for finding the closest cities
+(or weather stations) quickly when there are many of them. It is a k-d tree
+over the points' positions on a unit sphere, where the straight-line
+(chord) distance always orders points the same way as the great-circle
+distance, so the tree works anywhere on the globe, across the date line
+and near the poles.*/
+
+package solar
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+//Index finds the cities closest to a location, or all of the cities within
+//a distance of it, without looking at every city. An Index is never changed
+//after NewIndex returns it, so any number of goroutines can search it.
+type Index struct {
+	points []indexPoint
+	nodes  []kdNode
+	root   int
+}
+
+//A city and its position on the unit sphere.
+type indexPoint struct {
+	city City
+	xyz  [3]float64
+}
+
+//One node of the tree. left and right are indices into nodes, -1 if none.
+type kdNode struct {
+	point       int
+	axis        int
+	left, right int
+}
+
+//Builds the Index of the cities. Building takes O(n log² n) time; every
+//search after that visits only a small part of the tree.
+func NewIndex(cityData map[string]City) *Index {
+	index := &Index{root: -1}
+	names := make([]string, 0, len(cityData))
+	for name := range cityData {
+		names = append(names, name)
+	}
+	sort.Strings(names) //the same cities always build the same tree
+	for _, name := range names {
+		city := cityData[name]
+		index.points = append(index.points, indexPoint{city, unitVector(city.Latitude(), city.Longitude())})
+	}
+	order := make([]int, len(index.points))
+	for i := range order {
+		order[i] = i
+	}
+	index.nodes = make([]kdNode, 0, len(index.points))
+	index.root = index.build(order, 0)
+	return index
+}
+
+//Gives the number of cities in the Index.
+func (index *Index) Len() int {
+	return len(index.points)
+}
+
+//Builds the subtree of the points in order and gives its node index.
+func (index *Index) build(order []int, depth int) int {
+	if len(order) == 0 {
+		return -1
+	}
+	axis := depth % 3
+	sort.Slice(order, func(i, j int) bool {
+		return index.points[order[i]].xyz[axis] < index.points[order[j]].xyz[axis]
+	})
+	median := len(order) / 2
+	node := len(index.nodes)
+	index.nodes = append(index.nodes, kdNode{point: order[median], axis: axis})
+	left := index.build(order[:median], depth+1)
+	right := index.build(order[median+1:], depth+1)
+	index.nodes[node].left = left
+	index.nodes[node].right = right
+	return node
+}
+
+//Gives the position of a latitude and longitude on a sphere of radius 1.
+func unitVector(lat, lon float64) [3]float64 {
+	toRad := math.Pi / 180
+	cosLat := math.Cos(lat * toRad)
+	return [3]float64{cosLat * math.Cos(lon*toRad), cosLat * math.Sin(lon*toRad), math.Sin(lat * toRad)}
+}
+
+//Gives the squared straight-line distance between two points.
+func chordSquared(a, b [3]float64) float64 {
+	dx, dy, dz := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return dx*dx + dy*dy + dz*dz
+}
+
+//Finds the k cities closest to a location, closest first. Ties go to the
+//first name alphabetically.
+func (index *Index) Nearest(lat, lon float64, k int) []Nearest {
+	if k <= 0 || index.root < 0 {
+		return nil
+	}
+	target := unitVector(lat, lon)
+	best := &candidateHeap{}
+	index.nearest(index.root, target, k, best)
+	sort.Sort(sort.Reverse(best))
+	return index.results(lat, lon, *best)
+}
+
+//Searches the subtree at node, keeping the k best candidates in best.
+func (index *Index) nearest(node int, target [3]float64, k int, best *candidateHeap) {
+	if node < 0 {
+		return
+	}
+	n := index.nodes[node]
+	p := index.points[n.point]
+	best.offer(candidate{n.point, chordSquared(target, p.xyz), p.city.Name}, k)
+
+	diff := target[n.axis] - p.xyz[n.axis]
+	near, far := n.left, n.right
+	if diff > 0 {
+		near, far = n.right, n.left
+	}
+	index.nearest(near, target, k, best)
+	//the other side can only hold a closer point if the splitting plane is closer than the worst candidate
+	if best.Len() < k || diff*diff <= (*best)[0].distance {
+		index.nearest(far, target, k, best)
+	}
+}
+
+//Finds all of the cities within radiusKm of a location, closest first.
+func (index *Index) Within(lat, lon, radiusKm float64) []Nearest {
+	if radiusKm < 0 || index.root < 0 {
+		return nil
+	}
+	target := unitVector(lat, lon)
+	angle := math.Min(radiusKm/EarthRadiusKm, math.Pi)
+	limit := math.Pow(2*math.Sin(angle/2), 2) * (1 + 1e-12) //chord length of the radius, squared
+	found := make(candidateHeap, 0)
+	index.within(index.root, target, limit, &found)
+	sort.Sort(sort.Reverse(&found))
+	return index.results(lat, lon, found)
+}
+
+//Collects the points of the subtree at node that are within limit.
+func (index *Index) within(node int, target [3]float64, limit float64, found *candidateHeap) {
+	if node < 0 {
+		return
+	}
+	n := index.nodes[node]
+	p := index.points[n.point]
+	if d := chordSquared(target, p.xyz); d <= limit {
+		*found = append(*found, candidate{n.point, d, p.city.Name})
+	}
+	diff := target[n.axis] - p.xyz[n.axis]
+	if diff <= 0 || diff*diff <= limit {
+		index.within(n.left, target, limit, found)
+	}
+	if diff >= 0 || diff*diff <= limit {
+		index.within(n.right, target, limit, found)
+	}
+}
+
+//Turns the candidates into Nearest results with great-circle distances.
+func (index *Index) results(lat, lon float64, found []candidate) []Nearest {
+	nearest := make([]Nearest, 0, len(found))
+	for _, c := range found {
+		city := index.points[c.point].city
+		distance := Haversine(lat, lon, city.Latitude(), city.Longitude())
+		nearest = append(nearest, Nearest{city.Name, distance, distance * MilesPerKm})
+	}
+	return nearest
+}
+
+//Gives the City stored in the Index under name.
+func (index *Index) city(name string) City {
+	i := sort.Search(len(index.points), func(i int) bool { return index.points[i].city.Name >= name })
+	if i < len(index.points) && index.points[i].city.Name == name {
+		return index.points[i].city
+	}
+	return City{}
+}
+
+//A point found by a search and its squared chord distance.
+type candidate struct {
+	point    int
+	distance float64
+	name     string
+}
+
+//candidateHeap is a max-heap of candidates, so the worst of the k best is
+//on top and can be dropped when a closer point is found.
+type candidateHeap []candidate
+
+func (h candidateHeap) Len() int { return len(h) }
+func (h candidateHeap) Less(i, j int) bool {
+	if h[i].distance != h[j].distance {
+		return h[i].distance > h[j].distance
+	}
+	return h[i].name > h[j].name
+}
+func (h candidateHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *candidateHeap) Push(x interface{}) { *h = append(*h, x.(candidate)) }
+func (h *candidateHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+//Adds c if there are fewer than k candidates or it beats the worst one.
+func (h *candidateHeap) offer(c candidate, k int) {
+	if h.Len() < k {
+		heap.Push(h, c)
+	} else if c.distance < (*h)[0].distance || (c.distance == (*h)[0].distance && c.name < (*h)[0].name) {
+		(*h)[0] = c
+		heap.Fix(h, 0)
+	}
+}
@@ -0,0 +1,179 @@
+package solar
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+//Makes n stations spread evenly over the globe.
+func randomStations(n int, seed int64) map[string]City {
+	rng := rand.New(rand.NewSource(seed))
+	cities := make(map[string]City, n)
+	for i := 0; i < n; i++ {
+		lat := math.Asin(2*rng.Float64()-1) * 180 / math.Pi
+		lon := rng.Float64()*360 - 180
+		name := fmt.Sprintf("S%06d", i)
+		cities[name] = City{Name: name, CoordN: lat, CoordW: -lon}
+	}
+	return cities
+}
+
+//Makes cities at the given latitudes and longitudes (east is positive).
+func placedCities(points ...[2]float64) map[string]City {
+	cities := make(map[string]City, len(points))
+	for i, p := range points {
+		name := fmt.Sprintf("C%d", i)
+		cities[name] = City{Name: name, CoordN: p[0], CoordW: -p[1]}
+	}
+	return cities
+}
+
+//Finds the k closest cities by measuring the distance to every city.
+func linearNearest(cities map[string]City, lat, lon float64, k int) []Nearest {
+	all := linearWithin(cities, lat, lon, math.Inf(1))
+	if k < len(all) {
+		all = all[:k]
+	}
+	return all
+}
+
+//Finds the cities within radiusKm by measuring the distance to every city.
+func linearWithin(cities map[string]City, lat, lon, radiusKm float64) []Nearest {
+	found := make([]Nearest, 0)
+	for _, city := range cities {
+		d := Haversine(lat, lon, city.Latitude(), city.Longitude())
+		if d <= radiusKm {
+			found = append(found, Nearest{city.Name, d, d * MilesPerKm})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].DistanceKm != found[j].DistanceKm {
+			return found[i].DistanceKm < found[j].DistanceKm
+		}
+		return found[i].City < found[j].City
+	})
+	return found
+}
+
+//Reports the differences between what the index found and the linear scan.
+//Distances are compared, as cities the same distance away can come in
+//either order.
+func compareResults(t *testing.T, query string, got, want []Nearest) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s: got %d cities, want %d", query, len(got), len(want))
+		return
+	}
+	for i := range got {
+		if math.Abs(got[i].DistanceKm-want[i].DistanceKm) > 1e-6 {
+			t.Errorf("%s: result %d is %s at %.6f km, want %s at %.6f km",
+				query, i, got[i].City, got[i].DistanceKm, want[i].City, want[i].DistanceKm)
+		}
+	}
+}
+
+func TestIndexNearestMatchesLinearScan(t *testing.T) {
+	cities := randomStations(5000, 1)
+	index := NewIndex(cities)
+	rng := rand.New(rand.NewSource(2))
+	for q := 0; q < 300; q++ {
+		lat := rng.Float64()*180 - 90
+		lon := rng.Float64()*360 - 180
+		for _, k := range []int{1, 7} {
+			query := fmt.Sprintf("Nearest(%.3f, %.3f, %d)", lat, lon, k)
+			compareResults(t, query, index.Nearest(lat, lon, k), linearNearest(cities, lat, lon, k))
+		}
+	}
+}
+
+func TestIndexWithinMatchesLinearScan(t *testing.T) {
+	cities := randomStations(5000, 3)
+	index := NewIndex(cities)
+	rng := rand.New(rand.NewSource(4))
+	for q := 0; q < 300; q++ {
+		lat := rng.Float64()*180 - 90
+		lon := rng.Float64()*360 - 180
+		radius := rng.Float64() * 1500
+		query := fmt.Sprintf("Within(%.3f, %.3f, %.0f)", lat, lon, radius)
+		compareResults(t, query, index.Within(lat, lon, radius), linearWithin(cities, lat, lon, radius))
+	}
+}
+
+func TestIndexEdgeCases(t *testing.T) {
+	tests := []struct {
+		name     string
+		cities   map[string]City
+		lat, lon float64
+		k        int
+	}{
+		{"across the date line", placedCities([2]float64{10, 179.9}, [2]float64{10, -179.9}, [2]float64{10, 178}), 10, -179.99, 2},
+		{"on the date line", placedCities([2]float64{-20, 179.5}, [2]float64{-20, -179.4}, [2]float64{-20, 170}), -20, 180, 3},
+		{"at the north pole", placedCities([2]float64{89.9, 0}, [2]float64{89.8, 90}, [2]float64{89.95, -170}, [2]float64{80, 0}), 90, 45, 3},
+		{"near the south pole", placedCities([2]float64{-89.99, 120}, [2]float64{-89.5, -60}, [2]float64{-85, 0}), -89.9, -60, 2},
+		{"k more than the cities", placedCities([2]float64{0, 0}, [2]float64{1, 1}, [2]float64{2, 2}), 0.5, 0.5, 10},
+	}
+	for _, test := range tests {
+		got := NewIndex(test.cities).Nearest(test.lat, test.lon, test.k)
+		compareResults(t, test.name, got, linearNearest(test.cities, test.lat, test.lon, test.k))
+	}
+	if got := NewIndex(nil).Nearest(0, 0, 1); len(got) != 0 {
+		t.Errorf("an empty index found %v", got)
+	}
+	if got := NewIndex(placedCities([2]float64{0, 0})).Nearest(0, 0, 0); len(got) != 0 {
+		t.Errorf("k = 0 found %v", got)
+	}
+}
+
+func TestIndexWithinRadiusEdge(t *testing.T) {
+	cities := placedCities([2]float64{0, 0}, [2]float64{1, 0}, [2]float64{0, 2})
+	index := NewIndex(cities)
+	edge := Haversine(0, 0, 1, 0) //the second city is exactly this far away
+	if got := index.Within(0, 0, edge); len(got) != 2 {
+		t.Errorf("Within the distance of a city: got %v, want the first two cities", got)
+	}
+	if got := index.Within(0, 0, edge-0.001); len(got) != 1 {
+		t.Errorf("Within a meter less than the distance of a city: got %v, want the first city", got)
+	}
+	if got := index.Within(0, 0, 0); len(got) != 1 || got[0].City != "C0" {
+		t.Errorf("Within 0 km of a city: got %v, want the city", got)
+	}
+	if got := index.Within(0, 0, -1); len(got) != 0 {
+		t.Errorf("Within a negative radius: got %v, want nothing", got)
+	}
+	if got := index.Within(0, 0, math.Pi*EarthRadiusKm); len(got) != 3 {
+		t.Errorf("Within half the earth's circumference: got %d cities, want all 3", len(got))
+	}
+}
+
+//Queries for the benchmarks, the same every run.
+func benchmarkQueries(n int) [][2]float64 {
+	rng := rand.New(rand.NewSource(6))
+	queries := make([][2]float64, n)
+	for i := range queries {
+		queries[i] = [2]float64{math.Asin(2*rng.Float64()-1) * 180 / math.Pi, rng.Float64()*360 - 180}
+	}
+	return queries
+}
+
+func BenchmarkNearest(b *testing.B) {
+	index := NewIndex(randomStations(100000, 5))
+	queries := benchmarkQueries(1024)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q := queries[i%len(queries)]
+		index.Nearest(q[0], q[1], 4)
+	}
+}
+
+func BenchmarkWithin(b *testing.B) {
+	index := NewIndex(randomStations(100000, 5))
+	queries := benchmarkQueries(1024)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q := queries[i%len(queries)]
+		index.Within(q[0], q[1], 100) //about 6 stations on average
+	}
+}
@@ -0,0 +1,88 @@
+/*Description: This file makes an hourly profile of a home's electricity
+use over a typical year from its yearly use, its size and the city's
+climate. Part of the use follows the daily routine of the household, and
+part follows the heating and cooling the weather calls for, so the bills,
+self-consumption and battery simulations see when the energy is used.*/
+
+package solar
+
+import "math"
+
+//Outdoor temperature below which a home heats and above which it cools (Fahrenheit).
+const BalanceTemp = 65
+
+//Difference between the warmest and coolest hour of an average day (Fahrenheit).
+const DailySwing = 20
+
+//Electricity used for cooling and heating for every degree above or below
+//BalanceTemp in an hour, per 1000 square feet (kwh). Cooling is nearly
+//always electric; only some homes heat with electricity.
+const (
+	CoolingPerDegreeHour = 0.0225
+	HeatingPerDegreeHour = 0.006
+)
+
+//Largest share of the yearly use that heating and cooling can take; the
+//rest is lights and appliances.
+const MaxWeatherShare = 0.7
+
+//Share of the household routine's use in each hour of the day (midnight
+//first): low at night, a morning peak and a larger evening peak.
+var DailyRoutine = [24]float64{0.6, 0.5, 0.45, 0.45, 0.45, 0.5, 0.7, 0.9, 0.9, 0.8, 0.75, 0.75,
+	0.75, 0.75, 0.75, 0.8, 0.95, 1.2, 1.4, 1.5, 1.45, 1.3, 1.0, 0.8}
+
+//Gives the outdoor temperature (Fahrenheit) in an hour of the year (0 is
+//1 January 0:00). It follows the seasons around the city's average, with
+//a swing that is larger farther from the equator, and is warmest in the
+//afternoon.
+func HourlyTemperature(city City, hour int) float64 {
+	lat := city.Latitude()
+	day := float64(hour/24 + 1)
+	seasonal := -0.5 * lat * math.Cos(2*math.Pi*(day-21)/365) //coldest around 21 January in the north, July in the south
+	daily := DailySwing / 2 * math.Cos(2*math.Pi*float64(hour%24-15)/24)
+	return city.Temp + seasonal + daily
+}
+
+//Makes the energy used in each hour of the year (kwh, HoursPerYear
+//values) by a home of houseSize square feet using annual kwh a year. The
+//heating and cooling follow the hourly temperature; what is left of the
+//yearly use follows DailyRoutine. The hours add up to annual.
+func LoadProfile(annual, houseSize float64, city City) []float64 {
+	load := make([]float64, HoursPerYear)
+	var weather float64
+	for h := range load {
+		temp := HourlyTemperature(city, h)
+		load[h] = houseSize / 1000 * (CoolingPerDegreeHour*math.Max(0, temp-BalanceTemp) +
+			HeatingPerDegreeHour*math.Max(0, BalanceTemp-temp))
+		weather += load[h]
+	}
+	if weather > MaxWeatherShare*annual {
+		for h := range load {
+			load[h] *= MaxWeatherShare * annual / weather
+		}
+		weather = MaxWeatherShare * annual
+	}
+	var routine float64
+	for _, share := range DailyRoutine {
+		routine += share
+	}
+	daily := (annual - weather) / (HoursPerYear / 24)
+	for h := range load {
+		load[h] += daily * DailyRoutine[h%24] / routine
+	}
+	return load
+}
+
+//Gives the energy of each month of an hourly profile of a year.
+func MonthlyTotals(hourly []float64) [12]float64 {
+	var monthly [12]float64
+	start := 0
+	for m := range monthly {
+		end := start + int(MonthDays[m])*24
+		for h := start; h < end && h < len(hourly); h++ {
+			monthly[m] += hourly[h]
+		}
+		start = end
+	}
+	return monthly
+}
@@ -0,0 +1,86 @@
+package solar
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHourlyTemperature(t *testing.T) {
+	tests := []struct {
+		name string
+		city City
+		hour int
+		want float64
+	}{
+		{"equator at 15:00", City{Temp: 70}, 15, 80},
+		{"equator at 3:00", City{Temp: 70}, 3, 60},
+		{"equator in July", City{Temp: 70}, 190*24 + 15, 80},
+		//21 January is 20 degrees below the average at 40 degrees north.
+		{"north in winter", City{CoordN: 40, Temp: 55}, 20*24 + 15, 45},
+		{"south in winter", City{CoordN: -40, Temp: 55}, 20*24 + 3, 65},
+	}
+	for _, test := range tests {
+		if got := HourlyTemperature(test.city, test.hour); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%s: got %g F, want %g", test.name, got, test.want)
+		}
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	//On the equator at 65 F the temperature only follows the day, 10
+	//degrees either side of 65: 75.96 degree hours of cooling and as many
+	//of heating a day, 790.15 kwh a year for 1000 square feet. At 9:00
+	//it is 65 F and only the routine, 0.8 of its 20.4, is left; at 15:00
+	//it is 75 F and 0.225 kwh of cooling is added to the same 0.8.
+	equator := City{Temp: 65}
+	tests := []struct {
+		name      string
+		annual    float64
+		at9, at15 float64
+	}{
+		{"weather below the cap", 10000, (10000 - 790.15) / 365 * 0.8 / 20.4, 0.225 + (10000-790.15)/365*0.8/20.4},
+		//790.15 is more than 70% of 1000: heating and cooling are scaled to 700.
+		{"weather capped", 1000, 300.0 / 365 * 0.8 / 20.4, 0.225*700/790.15 + 300.0/365*0.8/20.4},
+	}
+	for _, test := range tests {
+		load := LoadProfile(test.annual, 1000, equator)
+		if len(load) != HoursPerYear {
+			t.Fatalf("%s: got %d hours, want %d", test.name, len(load), HoursPerYear)
+		}
+		var total float64
+		for _, kwh := range load {
+			total += kwh
+		}
+		if math.Abs(total-test.annual) > 1e-6 {
+			t.Errorf("%s: the hours add up to %g kwh, want %g", test.name, total, test.annual)
+		}
+		if math.Abs(load[9]-test.at9) > 1e-4 || math.Abs(load[15]-test.at15) > 1e-4 {
+			t.Errorf("%s: got %g kwh at 9:00 and %g at 15:00, want %g and %g", test.name, load[9], load[15], test.at9, test.at15)
+		}
+	}
+
+	//40 degrees from the equator at 60 F: winters 20 degrees colder, summers
+	//20 warmer and mild springs. Cooling takes more than heating.
+	for _, lat := range []float64{40, -40} {
+		monthly := MonthlyTotals(LoadProfile(12000, 2000, City{CoordN: lat, Temp: 60}))
+		winter, summer := monthly[0], monthly[6]
+		if lat < 0 {
+			winter, summer = summer, winter
+		}
+		if !(summer > winter && winter > monthly[3] && winter > monthly[9]) {
+			t.Errorf("latitude %g: got %.0f kwh in January, %.0f in April, %.0f in July and %.0f in October",
+				lat, monthly[0], monthly[3], monthly[6], monthly[9])
+		}
+	}
+}
+
+func TestMonthlyTotals(t *testing.T) {
+	monthly := MonthlyTotals(steadyLoad)
+	if monthly[0] != 744 || monthly[1] != 672 || monthly[3] != 720 {
+		t.Errorf("1 kwh an hour: got %g, %g and %g kwh in January, February and April, want 744, 672 and 720",
+			monthly[0], monthly[1], monthly[3])
+	}
+	if monthly := MonthlyTotals(steadyLoad[:24*40]); monthly[1] != 9*24 || monthly[2] != 0 {
+		t.Errorf("40 days: got %g kwh in February and %g in March, want 216 and 0", monthly[1], monthly[2])
+	}
+}
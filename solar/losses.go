@@ -0,0 +1,78 @@
+/*Description: This file holds the system losses, the share of the panels'
+rated output that is lost before it reaches the house. It follows the loss
+categories and defaults of NREL's PVWatts, so installers can explain each
+part of the estimate.*/
+
+package solar
+
+//These are the losses of a solar panel system, each as a percentage of
+//the energy. The inverter is given as its efficiency instead.
+type Losses struct {
+	Soiling            float64 `json:"soiling"`            //Dirt, dust and pollen on the panels
+	Shading            float64 `json:"shading"`            //Trees, buildings and the panels themselves
+	Snow               float64 `json:"snow"`               //Snow covering the panels
+	Mismatch           float64 `json:"mismatch"`           //Panels in a string that are not exactly alike
+	Wiring             float64 `json:"wiring"`             //Resistance in the DC and AC wires
+	Connections        float64 `json:"connections"`        //Resistance in the connectors
+	LID                float64 `json:"lid"`                //Light induced degradation in the first months
+	Nameplate          float64 `json:"nameplate"`          //Panels making less than their rated power
+	Availability       float64 `json:"availability"`       //System turned off for maintenance or grid outages
+	InverterEfficiency float64 `json:"inverterEfficiency"` //Share of the DC power the inverter turns into AC
+}
+
+//This is one line of the loss breakdown.
+type LossItem struct {
+	Key     string  `json:"key"`     //JSON name of the loss in Losses
+	Name    string  `json:"name"`    //Name to show to the user
+	Percent float64 `json:"percent"` //Share of the energy lost
+}
+
+//Gives the PVWatts default losses: 14.08% before the inverter and a 96%
+//efficient inverter, about 17.5% in all.
+func DefaultLosses() Losses {
+	return Losses{
+		Soiling:            2,
+		Shading:            3,
+		Snow:               0,
+		Mismatch:           2,
+		Wiring:             2,
+		Connections:        0.5,
+		LID:                1.5,
+		Nameplate:          1,
+		Availability:       3,
+		InverterEfficiency: 96,
+	}
+}
+
+//Gives the share of the rated output left after all of the losses. The
+//losses multiply, like in PVWatts, instead of adding up.
+func (l Losses) Factor() float64 {
+	factor := l.InverterEfficiency / 100
+	for _, item := range l.Items() {
+		if item.Key != "inverterEfficiency" {
+			factor *= 1 - item.Percent/100
+		}
+	}
+	return factor
+}
+
+//Gives the total loss (percentage) of Factor, rounded to a hundredth.
+func (l Losses) Total() float64 {
+	return float64(int((1-l.Factor())*10000)) / 100
+}
+
+//Gives every loss in order, with the inverter's efficiency turned into its loss.
+func (l Losses) Items() []LossItem {
+	return []LossItem{
+		{"soiling", "Soiling", l.Soiling},
+		{"shading", "Shading", l.Shading},
+		{"snow", "Snow", l.Snow},
+		{"mismatch", "Mismatch", l.Mismatch},
+		{"wiring", "Wiring", l.Wiring},
+		{"connections", "Connections", l.Connections},
+		{"lid", "Light induced degradation", l.LID},
+		{"nameplate", "Nameplate rating", l.Nameplate},
+		{"availability", "Availability", l.Availability},
+		{"inverterEfficiency", "Inverter", float64(int((100-l.InverterEfficiency)*100)) / 100},
+	}
+}
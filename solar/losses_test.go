@@ -0,0 +1,32 @@
+package solar
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLosses(t *testing.T) {
+	//PVWatts: 0.98 * 0.97 * 1 * 0.98 * 0.98 * 0.995 * 0.985 * 0.99 * 0.97 =
+	//0.85924 is left before the inverter, a loss of 14.08%, and the 96%
+	//efficient inverter leaves 0.82487, a loss of 17.51%.
+	losses := DefaultLosses()
+	if factor := losses.Factor(); math.Abs(factor-0.859243*0.96) > 1e-6 || losses.Total() != 17.51 {
+		t.Errorf("the defaults: got a factor of %g and a total of %g%%, want 0.82487 and 17.51%%", factor, losses.Total())
+	}
+	losses.InverterEfficiency = 100
+	if total := losses.Total(); total != 14.07 {
+		t.Errorf("the defaults without the inverter: got %g%%, want 14.07%% (14.0757 truncated)", total)
+	}
+
+	items := DefaultLosses().Items()
+	if len(items) != 10 || items[0] != (LossItem{"soiling", "Soiling", 2}) || items[9] != (LossItem{"inverterEfficiency", "Inverter", 4}) {
+		t.Errorf("got the items %v", items)
+	}
+	if factor := (Losses{InverterEfficiency: 100}).Factor(); factor != 1 {
+		t.Errorf("no losses: got %g, want 1", factor)
+	}
+	//The losses multiply: 10% and 10% lose 19%, not 20%.
+	if factor := (Losses{Soiling: 10, Shading: 10, InverterEfficiency: 100}).Factor(); math.Abs(factor-0.81) > 1e-9 {
+		t.Errorf("10%% and 10%%: got %g, want 0.81", factor)
+	}
+}
@@ -0,0 +1,108 @@
+/*Description: This file gives every city 12 monthly solar radiation values
+instead of one yearly average, so the estimate can show the seasons. The
+values come from monthly.csv when the city is listed there, otherwise they
+are worked out from the city's latitude and yearly average.*/
+
+package solar
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//Makes a map from city name to its 12 monthly solar radiation values
+//(kwh/m^2/day on a flat surface, January first). Each row of the file is
+//the city name followed by the 12 values.
+func MakeMonthlyMap(filename string) (map[string][12]float64, error) {
+	lines, err := ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	monthly := make(map[string][12]float64)
+	for i := 0; i < len(lines); i++ {
+		var items []string = strings.Split(lines[i], ",")
+		if BlankLine(items) {
+			continue
+		}
+		if len(items) != 13 {
+			return nil, lineError(fmt.Errorf("expected 13 columns, the city and 12 months, found %d", len(items)), filename, i+1)
+		}
+		cityName := strings.TrimSpace(items[0])
+		if cityName == "" {
+			return nil, lineError(&ParseError{Column: 1, Value: items[0], Err: errors.New("missing city name")}, filename, i+1)
+		}
+		if _, ok := monthly[cityName]; ok {
+			return nil, lineError(fmt.Errorf("city %q is listed twice", cityName), filename, i+1)
+		}
+		var values [12]float64
+		for m := range values {
+			values[m], err = ParseColumn(items, m+1)
+			if err == nil && values[m] < 0 {
+				err = &ParseError{Column: m + 2, Value: items[m+1], Err: errors.New("solar radiation can't be negative")}
+			}
+			if err != nil {
+				return nil, lineError(err, filename, i+1)
+			}
+		}
+		monthly[cityName] = values
+	}
+	return monthly, nil
+}
+
+//Works out 12 monthly solar radiation values from the city's yearly
+//average, assuming the share of sunlight that gets through the atmosphere
+//is the same all year. The seasons then follow the sunlight reaching the
+//top of the atmosphere at the city's latitude, and the monthly values
+//average out to SolarRad over the year.
+func SynthesizeMonthly(city City) [12]float64 {
+	var extraterrestrial [12]float64
+	var yearly float64
+	for m := range extraterrestrial {
+		extraterrestrial[m] = DailyExtraterrestrial(city.Latitude(), RepresentativeDays[m])
+		yearly += extraterrestrial[m] * MonthDays[m]
+	}
+	var monthly [12]float64
+	if yearly <= 0 {
+		return monthly
+	}
+	for m := range monthly {
+		monthly[m] = city.SolarRad * extraterrestrial[m] * 365 / yearly
+	}
+	return monthly
+}
+
+//Gives the city's 12 monthly solar radiation values on a flat surface
+//(kwh/m^2/day), from monthly.csv if it was loaded, or else worked out by
+//SynthesizeMonthly.
+func (c City) Monthly() [12]float64 {
+	if c.MonthlyRad != nil {
+		return *c.MonthlyRad
+	}
+	return SynthesizeMonthly(c)
+}
+
+//Calculates the expected generated energy from solar panels in each month
+//(kwh), with the same formula as SolarOutput. angleType is "horizontal" or
+//"optimal"; the optimal values scale every month by OptRad/SolarRad.
+func MonthlyOutput(city City, angleType string, efficiency, roofSize float64, losses Losses) [12]float64 {
+	radiation := city.Monthly()
+	if angleType == "optimal" && city.SolarRad > 0 {
+		for m := range radiation {
+			radiation[m] *= city.OptRad / city.SolarRad
+		}
+	}
+	return monthlyEnergy(radiation, efficiency, roofSize, losses)
+}
+
+//Turns 12 monthly radiation values (kwh/m^2/day) into the energy generated
+//in each month (kwh).
+func monthlyEnergy(radiation [12]float64, efficiency, roofSize float64, losses Losses) [12]float64 {
+	var output [12]float64
+	for m := range output {
+		month := City{SolarRad: radiation[m]}
+		//SolarOutput gives an average month; stretch it to this month's days
+		output[m] = SolarOutput(month, "horizontal", efficiency, roofSize, losses) * MonthDays[m] / (365.0 / 12)
+	}
+	return output
+}
@@ -0,0 +1,91 @@
+package solar
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//Writes a file for a test and gives its path.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMakeMonthlyMap(t *testing.T) {
+	path := writeFile(t, "monthly.csv", "Boston, 1.9,2.7,3.6,4.5,5.5,6.0,6.0,5.3,4.3,3.0,1.9,1.6\r\n\r\n")
+	monthly, err := MakeMonthlyMap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := monthly["Boston"]; got[0] != 1.9 || got[6] != 6 || got[11] != 1.6 {
+		t.Errorf("got %v", got)
+	}
+
+	tests := []struct {
+		name, content, err string
+	}{
+		{"too few months", "Boston,1,2,3,4,5,6,7,8,9,10,11\n", "monthly.csv:1: expected 13 columns, the city and 12 months, found 12"},
+		{"too many months", "Boston,1,2,3,4,5,6,7,8,9,10,11,12,13\n", "found 14"},
+		{"no city", " ,1,2,3,4,5,6,7,8,9,10,11,12\n", "monthly.csv:1: column 1: bad value \" \": missing city name"},
+		{"a semicolon", "Boston;1;2;3;4;5;6;7;8;9;10;11;12\n", "found 1"},
+		{"listed twice", "Boston,1,2,3,4,5,6,7,8,9,10,11,12\nBoston,1,2,3,4,5,6,7,8,9,10,11,12\n", "monthly.csv:2: city \"Boston\" is listed twice"},
+		{"negative", "Boston,1,2,3,4,5,6,7,8,9,10,11,-12\n", "column 13"},
+		{"not a number", "Boston,1,2,3,4,five,6,7,8,9,10,11,12\n", "column 6"},
+	}
+	for _, test := range tests {
+		_, err := MakeMonthlyMap(writeFile(t, "monthly.csv", test.content))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got %v, want an error with %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestAddMonthly(t *testing.T) {
+	cities := []City{{Name: "Boston", SolarRad: 3.9}, {Name: "Phoenix", SolarRad: 5.7}}
+	if err := addMonthly(filepath.Join(t.TempDir(), "missing.csv"), cities); err != nil {
+		t.Errorf("a missing file: %v", err)
+	}
+	path := writeFile(t, "monthly.csv", "Boston,1,2,3,4,5,6,7,8,9,10,11,12\n")
+	if err := addMonthly(path, cities); err != nil {
+		t.Fatal(err)
+	}
+	if cities[0].MonthlyRad == nil || cities[0].Monthly()[11] != 12 {
+		t.Errorf("Boston: got %v, want the values of the file", cities[0].MonthlyRad)
+	}
+	if cities[1].MonthlyRad != nil {
+		t.Errorf("Phoenix isn't in the file but got %v", *cities[1].MonthlyRad)
+	}
+	path = writeFile(t, "monthly.csv", "Bostn,1,2,3,4,5,6,7,8,9,10,11,12\n")
+	if err := addMonthly(path, cities); err == nil || !strings.Contains(err.Error(), `city "Bostn" is not in the city data`) {
+		t.Errorf("a city that isn't in the city data: got %v", err)
+	}
+	path = writeFile(t, "monthly.csv", "Boston,1,2,3,4,5,6,7,8,9,10,11,12\nPhoenix,1,2,3\n")
+	if err := addMonthly(path, cities); err == nil || !strings.Contains(err.Error(), "monthly.csv:2:") {
+		t.Errorf("a bad second row: got %v", err)
+	}
+}
+
+func TestSynthesizeMonthly(t *testing.T) {
+	for _, city := range []City{{CoordN: 42.36, SolarRad: 3.9}, {CoordN: -33.9, SolarRad: 5}, {CoordN: 0, SolarRad: 5.5}} {
+		monthly := SynthesizeMonthly(city)
+		var yearly float64
+		for m, value := range monthly {
+			yearly += value * MonthDays[m]
+		}
+		if average := yearly / 365; math.Abs(average-city.SolarRad) > 1e-9 {
+			t.Errorf("latitude %g: the months average %g, want %g", city.CoordN, average, city.SolarRad)
+		}
+		//The summer months get more sunlight than the winter ones.
+		june, december := monthly[5], monthly[11]
+		if city.CoordN > 0 && june <= december || city.CoordN < 0 && june >= december {
+			t.Errorf("latitude %g: June %g, December %g", city.CoordN, june, december)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+/*Description: This file holds the Panel struct and the functions that read
+the solar panel data (solar.csv) into a map of Panel objects.*/
+
+package solar
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/* This is a panel struct which stores the information for each type of solar
+panel. Every row of solar.csv is one panel the user can choose from, with
+information on its efficiency (percentage), watts, panel area, price and
+optionally how its power changes with temperature.*/
+type Panel struct {
+	Name        string  //Brand name, the first column of solar.csv
+	Efficiency  float64 //Efficiency (percentage)
+	Watts       float64 //Rated power (watts)
+	Area        float64 //Panel area (m^2)
+	Price       float64 //Price of one panel (dollars)
+	TempCoeff   float64 //Change of power per degree Celsius above 25 (percentage, negative)
+	NOCT        float64 //Nominal operating cell temperature (Celsius)
+	Degradation float64 //Power lost every year (percentage)
+	LID         float64 //Power lost in the first year to light induced degradation (percentage)
+}
+
+//Make the map data structure of all of the different solar panel brands.
+//The first bad row stops the loading with an error.
+func MakeSolarMap(filename string) (map[string]Panel, error) {
+	panels, err := MakePanelList(filename)
+	if err != nil {
+		return nil, err
+	}
+	solarPanels := make(map[string]Panel)
+	for _, panel := range panels {
+		solarPanels[panel.Name] = panel
+	}
+	return solarPanels, nil
+}
+
+//Makes a slice of all of the Panel objects in the order of the file.
+func MakePanelList(filename string) ([]Panel, error) {
+	lines, err := ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	panels := make([]Panel, 0)
+	names := make(map[string]bool)
+	for i := 0; i < len(lines); i++ {
+		var items []string = strings.Split(lines[i], ",")
+		if BlankLine(items) {
+			continue
+		}
+		panel, err := MakePanel(items)
+		if err != nil {
+			return nil, lineError(err, filename, i+1)
+		}
+		if names[panel.Name] {
+			return nil, lineError(fmt.Errorf("panel %q is listed twice", panel.Name), filename, i+1)
+		}
+		names[panel.Name] = true
+		panels = append(panels, panel)
+	}
+	if len(panels) == 0 {
+		return nil, fmt.Errorf("%s: no panels found", filename)
+	}
+	return panels, nil
+}
+
+//Make a Solar Panel object using Panel struct. All of the numbers must be
+//positive, since the panel counts divide by the efficiency and area. The
+//temperature coefficient, NOCT, degradation and LID columns may be left
+//out; they then get DefaultTempCoeff, DefaultNOCT, DefaultDegradation and
+//DefaultLID.
+func MakePanel(items []string) (Panel, error) {
+	var panel Panel
+	var err error
+	if len(items) < 5 {
+		return panel, fmt.Errorf("expected at least 5 columns, found %d", len(items))
+	}
+	panel.Name = strings.TrimSpace(items[0])
+	if panel.Name == "" {
+		return panel, &ParseError{Column: 1, Value: items[0], Err: errors.New("missing panel name")}
+	}
+	numbers := []*float64{&panel.Efficiency, &panel.Watts, &panel.Area, &panel.Price}
+	for i, number := range numbers {
+		*number, err = ParseColumn(items, i+1)
+		if err != nil {
+			return panel, err
+		}
+		if *number <= 0 {
+			return panel, &ParseError{Column: i + 2, Value: items[i+1], Err: errors.New("must be positive")}
+		}
+	}
+	optional := []struct {
+		number   *float64
+		def      float64
+		min, max float64
+		name     string
+	}{
+		{&panel.TempCoeff, DefaultTempCoeff, -2, 0, "temperature coefficient"},
+		{&panel.NOCT, DefaultNOCT, 20, 80, "NOCT"},
+		{&panel.Degradation, DefaultDegradation, 0, 5, "degradation"},
+		{&panel.LID, DefaultLID, 0, 10, "LID"},
+	}
+	for i, column := range optional {
+		col := i + 5
+		*column.number = column.def
+		if len(items) <= col || strings.TrimSpace(items[col]) == "" {
+			continue
+		}
+		*column.number, err = ParseColumn(items, col)
+		if err == nil && (*column.number < column.min || *column.number > column.max) {
+			err = &ParseError{Column: col + 1, Value: items[col], Err: fmt.Errorf("%s must be between %g and %g", column.name, column.min, column.max)}
+		}
+		if err != nil {
+			return panel, err
+		}
+	}
+	return panel, nil
+}
@@ -0,0 +1,70 @@
+package solar
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMakePanelList(t *testing.T) {
+	panels, err := MakePanelList(writeFile(t, "solar.csv", "Kyocera,16,315,2.19,399,-0.46,45,0.8,0\n\nPlain,15,250,1.6,200\nSome,15,250,1.6,200,-0.3,,0.6\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Panel{
+		{Name: "Kyocera", Efficiency: 16, Watts: 315, Area: 2.19, Price: 399, TempCoeff: -0.46, NOCT: 45, Degradation: 0.8, LID: 0},
+		{Name: "Plain", Efficiency: 15, Watts: 250, Area: 1.6, Price: 200, TempCoeff: DefaultTempCoeff, NOCT: DefaultNOCT, Degradation: DefaultDegradation, LID: DefaultLID},
+		{Name: "Some", Efficiency: 15, Watts: 250, Area: 1.6, Price: 200, TempCoeff: -0.3, NOCT: DefaultNOCT, Degradation: 0.6, LID: DefaultLID},
+	}
+	if len(panels) != len(want) {
+		t.Fatalf("got %d panels, want %d", len(panels), len(want))
+	}
+	for i := range want {
+		if panels[i] != want[i] {
+			t.Errorf("got %+v, want %+v", panels[i], want[i])
+		}
+	}
+
+	tests := []struct {
+		name, content string
+		want          ParseError
+		message       string
+	}{
+		{"too few columns", "Kyocera,16,315,2.19\n",
+			ParseError{File: "solar.csv", Line: 1, Err: errors.New("expected at least 5 columns, found 4")},
+			"solar.csv:1: expected at least 5 columns, found 4"},
+		{"not a number", "Kyocera,16,315,big,399\n",
+			ParseError{File: "solar.csv", Line: 1, Column: 4, Value: "big", Err: errors.New("not a number")},
+			`solar.csv:1: column 4: bad value "big": not a number`},
+		{"no efficiency", "Plain,15,250,1.6,200\nKyocera,0,315,2.19,399\n",
+			ParseError{File: "solar.csv", Line: 2, Column: 2, Value: "0", Err: errors.New("must be positive")},
+			`solar.csv:2: column 2: bad value "0": must be positive`},
+		{"positive temperature coefficient", "Kyocera,16,315,2.19,399,0.4\n",
+			ParseError{File: "solar.csv", Line: 1, Column: 6, Value: "0.4", Err: errors.New("temperature coefficient must be between -2 and 0")},
+			`solar.csv:1: column 6: bad value "0.4": temperature coefficient must be between -2 and 0`},
+		{"NOCT in Fahrenheit", "Kyocera,16,315,2.19,399,-0.46,113\n",
+			ParseError{File: "solar.csv", Line: 1, Column: 7, Value: "113", Err: errors.New("NOCT must be between 20 and 80")},
+			`solar.csv:1: column 7: bad value "113": NOCT must be between 20 and 80`},
+		{"degradation", "Kyocera,16,315,2.19,399,-0.46,45,-0.5\n",
+			ParseError{File: "solar.csv", Line: 1, Column: 8, Value: "-0.5", Err: errors.New("degradation must be between 0 and 5")},
+			`solar.csv:1: column 8: bad value "-0.5": degradation must be between 0 and 5`},
+		{"LID as a share", "Kyocera,16,315,2.19,399,-0.46,45,0.8,15\n",
+			ParseError{File: "solar.csv", Line: 1, Column: 9, Value: "15", Err: errors.New("LID must be between 0 and 10")},
+			`solar.csv:1: column 9: bad value "15": LID must be between 0 and 10`},
+		{"NOCT not a number", "Kyocera,16,315,2.19,399,-0.46,NaN\n",
+			ParseError{File: "solar.csv", Line: 1, Column: 7, Value: "NaN", Err: errors.New("not a finite number")},
+			`solar.csv:1: column 7: bad value "NaN": not a finite number`},
+		{"listed twice", "Plain,15,250,1.6,200\nPlain,16,250,1.6,200\n",
+			ParseError{File: "solar.csv", Line: 2, Err: errors.New(`panel "Plain" is listed twice`)},
+			`solar.csv:2: panel "Plain" is listed twice`},
+	}
+	for _, test := range tests {
+		_, err := MakePanelList(writeFile(t, "solar.csv", test.content))
+		checkParseError(t, test.name, err, test.want, test.message)
+	}
+
+	if _, err := MakePanelList(filepath.Join(t.TempDir(), "missing.csv")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("a missing file: got %v", err)
+	}
+}
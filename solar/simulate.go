@@ -0,0 +1,94 @@
+/*Description: This file simulates a solar panel system hour by hour over a
+typical year of weather. For every hour it finds the sun, turns the
+measured sunlight onto the roof, works out how hot the panels get and
+gives the energy they produce. It is slower than SolarOutput but follows
+the weather of the site instead of one yearly average.*/
+
+package solar
+
+import "math"
+
+//Temperature values used when a panel's datasheet values aren't known.
+const (
+	DefaultTempCoeff = -0.4 //Change of power per degree Celsius of panel temperature (percentage)
+	DefaultNOCT      = 45.0 //Nominal operating cell temperature (Celsius)
+)
+
+//These are the parts of a solar panel system that the simulation needs.
+type SystemSpec struct {
+	Roof       Orientation //Pitch and direction of the panels
+	RoofSize   float64     //Area covered by panels (square feet)
+	Efficiency float64     //Panel efficiency (percentage)
+	TempCoeff  float64     //Change of power per degree Celsius (percentage, negative)
+	NOCT       float64     //Nominal operating cell temperature (Celsius)
+	Losses     Losses      //System losses other than temperature (DefaultLosses if zero)
+}
+
+//This is the result of a simulation over a typical year.
+type Simulation struct {
+	Station      string      `json:"station"`      //Weather station of the weather file
+	Annual       float64     `json:"annual"`       //Energy produced in the year (kwh)
+	Monthly      [12]float64 `json:"monthly"`      //Energy produced in each month (kwh)
+	Hourly       []float64   `json:"hourly"`       //Energy produced in each hour of the year (kwh), starting at 1 January 0:00
+	PlaneOfArray float64     `json:"planeOfArray"` //Sunlight reaching the panels in the year (kwh/m^2)
+	TempLoss     float64     `json:"tempLoss"`     //Share of the energy lost because the panels are not at 25 Celsius (percentage)
+}
+
+//Gives the system with the default temperature values filled in.
+func (system SystemSpec) withDefaults() SystemSpec {
+	if system.TempCoeff == 0 {
+		system.TempCoeff = DefaultTempCoeff
+	}
+	if system.NOCT == 0 {
+		system.NOCT = DefaultNOCT
+	}
+	if system.Losses == (Losses{}) {
+		system.Losses = DefaultLosses()
+	}
+	return system
+}
+
+//Simulates the system over every hour of the weather. The sun's position
+//is taken at the middle of each hour.
+func Simulate(weather *Weather, system SystemSpec) Simulation {
+	system = system.withDefaults()
+	area := system.RoofSize * 0.092903 //convert square feet to square meters
+	efficiency := system.Efficiency / 100
+	derate := system.Losses.Factor()
+	result := Simulation{Station: weather.Station, Hourly: make([]float64, len(weather.Hours))}
+	var at25 float64 //energy the panels would make at 25 Celsius
+	for i, hour := range weather.Hours {
+		day := hour.DayOfYear()
+		decl := Declination(day)
+		hourAngle := HourAngle(day, float64(hour.Hour)-0.5, weather.Longitude, weather.TimeZone)
+		cosZenith := CosZenith(weather.Latitude, decl, hourAngle)
+		cosIncidence := CosIncidence(weather.Latitude, decl, hourAngle, system.Roof)
+		poa := HourlyPlaneOfArray(hour.GHI, hour.DNI, hour.DHI, cosZenith, cosIncidence, ExtraterrestrialNormal(day), system.Roof)
+		if poa <= 0 {
+			continue
+		}
+		cellTemp := CellTemperature(hour.Temp, poa, hour.Wind, system.NOCT, efficiency)
+		dc := area * efficiency * poa / 1000 //kw at 25 Celsius
+		energy := dc * math.Max(0, 1+system.TempCoeff/100*(cellTemp-25)) * derate
+
+		result.Hourly[i] = energy
+		result.Monthly[hour.Month-1] += energy
+		result.Annual += energy
+		result.PlaneOfArray += poa / 1000
+		at25 += dc * derate
+	}
+	if at25 > 0 {
+		result.TempLoss = float64(int((1-result.Annual/at25)*10000)) / 100
+	}
+	return result
+}
+
+//Gives the temperature of the panels (Celsius) from the air temperature
+//(Celsius), the sunlight on them (W/m^2), the wind speed (m/s), their NOCT
+//(Celsius) and their efficiency (fraction). The NOCT is measured with 800
+//W/m^2 of sunlight, 20 Celsius air and a 1 m/s wind; more wind cools the
+//panels (Duffie and Beckman).
+func CellTemperature(air, sunlight, wind, noct, efficiency float64) float64 {
+	windFactor := 9.5 / (5.7 + 3.8*math.Max(0, wind))
+	return air + sunlight/800*(noct-20)*windFactor*(1-efficiency/0.9)
+}
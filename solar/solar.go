@@ -0,0 +1,157 @@
+/*Package solar contains the solar energy calculations behind the web app:
+reading the city and panel data, finding the closest city, estimating the
+solar energy output of a roof and comparing the cost of the panel brands.
+It has no dependency on the web server, so other programs can import it.*/
+package solar
+
+import "math"
+
+//Calculates expected generated energy from solar panels. (in kwh per month,
+//for an average month of 365/12 days) efficiency is a percentage.
+func SolarOutput(city City, angleType string, efficiency, roofSize float64, losses Losses) float64 {
+	var radiation float64
+	//the performance ratio is the share left after the system losses
+	// E = Solar Panel Area * solar panel efficiency * radiation * days in a month * performance ratio
+	if angleType == "horizontal" {
+		radiation = city.SolarRad
+	} else if angleType == "optimal" {
+		radiation = city.OptRad
+	}
+	roofSize *= 0.092903 //convert square feet to square meters
+	energyOutput := roofSize * efficiency / 100 * radiation * 365 * losses.Factor()
+	return energyOutput / 12
+}
+
+//Gives the potential optimal energy output for their home.
+func OptEnergy(city City, efficiency, roofSize float64, losses Losses) float64 {
+	return SolarOutput(city, "optimal", efficiency, roofSize, losses)
+}
+
+//Calculates average energy required per square foot of a house in the city. (kwh per month)
+func AverageEnergy(city City) float64 {
+	return city.AvgEnergy / 2600
+}
+
+//Gives a recommendation based on energy produced from solar panels and energy requirement.
+func IsItOptimal(avgUsage float64, solarOutput float64) (float64, string) {
+	percentage := solarOutput / avgUsage
+	if percentage <= 0.60 {
+		return percentage, "is not recommended"
+	} else if percentage > .60 && percentage < .8 {
+		return percentage, "is recommended"
+	} else {
+		return percentage, "is highly recommended"
+	}
+}
+
+//Calculates the installation cost.
+func InstallationCost(city City) float64 {
+	return city.InstCost * 5000
+}
+
+//This is one panel brand's option for a house: its monthly output on the
+//roof, how many panels are needed and what they would cost installed.
+type PanelOption struct {
+	Name       string             `json:"name"`
+	Efficiency float64            `json:"efficiency"`           //Efficiency (percentage)
+	Output     float64            `json:"output"`               //Expected output on the roof (kwh per month)
+	TempLoss   float64            `json:"tempLoss"`             //Output lost to the city's temperature (percentage, negative when the cold adds output)
+	NumPanels  int                `json:"numPanels"`            //Number of panels needed, no more than fit on the roof
+	Installed  float64            `json:"installed"`            //Expected output of the NumPanels panels (kwh per month)
+	Kw         float64            `json:"kw"`                   //Rated power of the NumPanels panels (kw)
+	Cost       int                `json:"cost"`                 //Cost of the panels and installation before incentives (dollars)
+	Incentives []AppliedIncentive `json:"incentives,omitempty"` //Incentives offered where the panels would be installed, if ApplyIncentives was called
+	NetCost    int                `json:"netCost"`              //Cost less the incentives paid up front (dollars)
+	Finance    *Finance           `json:"finance,omitempty"`    //Payback, NPV, IRR and LCOE, if AnalyzeOptions was called
+	Financing  []Financing        `json:"financing,omitempty"`  //Cash, loan, lease and PPA compared, if AnalyzeOptions was called
+}
+
+//Calculates the number of solar panels needed to cover the home's monthly
+//usage (kwh), from the monthly output of the panel covering the roof. A
+//home that uses more than the roof can make gets as many panels as fit.
+func NumSolarPanels(output, roofSize, usage float64, panel Panel) int {
+	roofSize *= 0.092903 //convert square feet to square meters
+	oneSolarPanelOutput := (output / roofSize) * panel.Area
+	if oneSolarPanelOutput <= 0 {
+		return 0 //no sunshine, no panels to buy
+	}
+	numPanels := math.Min(usage/oneSolarPanelOutput, roofSize/panel.Area)
+	return int(numPanels)
+}
+
+//Calculates how much it would cost for user to get that brand of solar panels on their house.
+func SolarPanelCost(city City, panel Panel, numPanels int) float64 {
+	cost := panel.Price * float64(numPanels)
+	return cost + InstallationCost(city)
+}
+
+//Calculates the output, cost and number of panels required for each brand of
+//solar panel on the roof, in the order of panels. The output is derated for
+//the city's temperature with each panel's temperature coefficient, and each
+//brand gets as many of its panels as cover usage, the home's monthly use (kwh).
+//Output is what the whole roof would make; Installed and Kw are the output
+//and power of the panels bought, which the cost pays for.
+func CalcCostBrand(usage, roofSize float64, city City, roof Orientation, losses Losses, panels []Panel) []PanelOption {
+	options := make([]PanelOption, 0, len(panels))
+	radiation := RoofRadiation(city, roof)
+	for _, panel := range panels {
+		factor := TemperatureFactor(city, panel, radiation)
+		output := RoofOutput(city, roof, panel.Efficiency, roofSize, losses) * factor
+		numPanels := NumSolarPanels(output, roofSize, usage, panel)
+		area := float64(numPanels) * panel.Area
+		installed := output * area / (roofSize * 0.092903) //convert square feet to square meters
+		options = append(options, PanelOption{
+			Name:       panel.Name,
+			Efficiency: panel.Efficiency,
+			Output:     float64(int(output*100)) / 100,
+			TempLoss:   TempLoss(factor),
+			NumPanels:  numPanels,
+			Installed:  float64(int(installed*100)) / 100,
+			Kw:         float64(int(area*panel.Efficiency/100*1000)) / 1000,
+			Cost:       int(SolarPanelCost(city, panel, numPanels)),
+			NetCost:    int(SolarPanelCost(city, panel, numPanels)),
+		})
+	}
+	return options
+}
+
+//Preferences in a slice, with 0: min cost, 1: max output, 2: max efficiency
+func Preferences(options []PanelOption) []string {
+	if len(options) == 0 {
+		return nil
+	}
+	return []string{FindMinCostPanel(options), FindMaxOutput(options), FindMostEfficient(options)}
+}
+
+//Gives the panel option that costs the least after the incentives.
+func FindMinCostPanel(options []PanelOption) string {
+	minCost := options[0]
+	for _, option := range options {
+		if option.NetCost < minCost.NetCost {
+			minCost = option
+		}
+	}
+	return minCost.Name
+}
+
+//Finds the brand of solar panel with the highest efficiency.
+func FindMostEfficient(options []PanelOption) string {
+	mostEfficient := options[0]
+	for _, option := range options {
+		if option.Efficiency > mostEfficient.Efficiency {
+			mostEfficient = option
+		}
+	}
+	return mostEfficient.Name
+}
+
+//Finds the panel brand with the highest output of solar energy.
+func FindMaxOutput(options []PanelOption) string {
+	maxOutput := options[0]
+	for _, option := range options {
+		if option.Output > maxOutput.Output {
+			maxOutput = option
+		}
+	}
+	return maxOutput.Name
+}
@@ -0,0 +1,91 @@
+package solar
+
+import (
+	"math"
+	"testing"
+)
+
+//Makes a year of weather with the same diffuse sunlight from 9:00 to 17:00
+//every day, dailyRad kwh/m^2 in all, and no sunlight at night. The air is
+//as much below 25 Celsius as a panel with the NOCT and efficiency heats up
+//in that sunlight, so the panel stays at 25 Celsius.
+func steadyWeather(dailyRad, noct, efficiency float64) *Weather {
+	const sunHours = 8
+	sunlight := dailyRad * 1000 / sunHours
+	air := 25 - CellTemperature(0, sunlight, 1, noct, efficiency) //how much the panels heat up
+	weather := &Weather{Station: "steady", Latitude: 35, Longitude: -106, TimeZone: -7}
+	eachHour(false, func(month, day, hour int) {
+		h := WeatherHour{Month: month, Day: day, Hour: hour, Temp: air, Wind: 1}
+		if hour > 9 && hour <= 9+sunHours {
+			h.GHI, h.DHI = sunlight, sunlight
+		}
+		weather.Hours = append(weather.Hours, h)
+	})
+	return weather
+}
+
+func TestSolarOutputMatchesSimulate(t *testing.T) {
+	city := City{SolarRad: 4.14}
+	losses := DefaultLosses()
+	weather := steadyWeather(city.SolarRad, 45, 0.16)
+	simulation := Simulate(weather, SystemSpec{RoofSize: 1000, Efficiency: 16, NOCT: 45, Losses: losses})
+
+	//1000 square feet of 16% panels in 4.14 kwh/m^2/day make
+	//92.903 m^2 * 0.16 * 4.14 * 365/12 days before the losses.
+	want := 92.903 * 0.16 * 4.14 * 365 / 12 * losses.Factor()
+	if got := SolarOutput(city, "horizontal", 16, 1000, losses); math.Abs(got-want) > 1e-6 {
+		t.Errorf("SolarOutput: got %.2f kwh, want %.2f", got, want)
+	}
+	if got := SolarOutput(city, "horizontal", 16, 1000, losses); math.Abs(got-simulation.Annual/12) > 1e-6 {
+		t.Errorf("SolarOutput gives %.2f kwh a month, Simulate %.2f", got, simulation.Annual/12)
+	}
+	if simulation.TempLoss != 0 {
+		t.Errorf("the panels lost %g%% to temperature, want 0", simulation.TempLoss)
+	}
+	flat := [12]float64{4.14, 4.14, 4.14, 4.14, 4.14, 4.14, 4.14, 4.14, 4.14, 4.14, 4.14, 4.14}
+	city.MonthlyRad = &flat
+	for m, got := range MonthlyOutput(city, "horizontal", 16, 1000, losses) {
+		if math.Abs(got-simulation.Monthly[m]) > 1e-6 {
+			t.Errorf("%s: MonthlyOutput gives %.2f kwh, Simulate %.2f", MonthNames[m], got, simulation.Monthly[m])
+		}
+	}
+}
+
+func TestNumSolarPanels(t *testing.T) {
+	//2 m^2 panels on 1000 square feet (92.903 m^2) making 1000 kwh a month
+	//make 21.53 kwh each; 600 kwh a month takes 27.9 of them.
+	if got := NumSolarPanels(1000, 1000, 600, Panel{Area: 2}); got != 27 {
+		t.Errorf("got %d panels, want 27", got)
+	}
+	if got := NumSolarPanels(0, 1000, 600, Panel{Area: 2}); got != 0 {
+		t.Errorf("no output: got %d panels, want 0", got)
+	}
+}
+
+func TestCalcCostBrand(t *testing.T) {
+	city := City{SolarRad: 5, Temp: 77, InstCost: 1}
+	panels := []Panel{{Name: "Twenty", Efficiency: 20, Area: 2, Price: 300}, {Name: "Ten", Efficiency: 10, Area: 2, Price: 100}}
+	losses := Losses{InverterEfficiency: 100}
+	//1000 square feet of 20% panels make 92.903 * 0.2 * 5 * 365/12 =
+	//2825.8 kwh a month, 60.83 kwh for each panel; the 10% panels half.
+	tests := []struct {
+		usage     float64
+		numPanels []int
+	}{
+		{600, []int{9, 19}},
+		{1200, []int{19, 39}},
+	}
+	for _, test := range tests {
+		options := CalcCostBrand(test.usage, 1000, city, Orientation{}, losses, panels)
+		for i, option := range options {
+			cost := int(panels[i].Price*float64(test.numPanels[i]) + 5000)
+			if option.NumPanels != test.numPanels[i] || option.Cost != cost {
+				t.Errorf("%g kwh of %s panels: got %d panels for %d, want %d for %d",
+					test.usage, option.Name, option.NumPanels, option.Cost, test.numPanels[i], cost)
+			}
+		}
+		if math.Abs(options[0].Output-2825.8) > 0.1 || math.Abs(options[1].Output-options[0].Output/2) > 0.01 {
+			t.Errorf("got %g and %g kwh a month, want 2825.8 and half of it", options[0].Output, options[1].Output)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+/*Description: This file holds the sun position formulas the other
+calculations need: the sun's declination, the length of the day and the
+solar energy that reaches the top of the atmosphere. Equations are from
+Duffie and Beckman, Solar Engineering of Thermal Processes.*/
+
+package solar
+
+import "math"
+
+//Solar constant (kW/m^2)
+const SolarConstant = 1.367
+
+//Days in each month of a year that is not a leap year.
+var MonthDays = [12]float64{31, 28, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+
+//Short month names, for tables and charts.
+var MonthNames = [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+
+//Day of the year whose sun is closest to the month's average (Klein).
+var RepresentativeDays = [12]int{17, 47, 75, 105, 135, 162, 198, 228, 258, 288, 318, 344}
+
+//Converts degrees to radians.
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+//Converts radians to degrees.
+func degrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
+
+//Gives the sun's declination on a day of the year (degrees, Cooper).
+func Declination(day int) float64 {
+	return 23.45 * math.Sin(radians(360*float64(284+day)/365))
+}
+
+//Gives the sunset hour angle (degrees) for a latitude and declination. It is
+//0 during polar night and 180 during the midnight sun.
+func SunsetHourAngle(lat, decl float64) float64 {
+	cosWs := -math.Tan(radians(lat)) * math.Tan(radians(decl))
+	return degrees(math.Acos(math.Max(-1, math.Min(1, cosWs))))
+}
+
+//Gives the hours from sunrise to sunset.
+func DayLength(lat float64, day int) float64 {
+	return 2 * SunsetHourAngle(lat, Declination(day)) / 15
+}
+
+//Gives the solar energy reaching a flat surface at the top of the
+//atmosphere over one day (kwh/m^2/day).
+func DailyExtraterrestrial(lat float64, day int) float64 {
+	decl := Declination(day)
+	ws := SunsetHourAngle(lat, decl)
+	phi, d, w := radians(lat), radians(decl), radians(ws)
+	eccentricity := 1 + 0.033*math.Cos(radians(360*float64(day)/365))
+	return 24 / math.Pi * SolarConstant * eccentricity *
+		(math.Cos(phi)*math.Cos(d)*math.Sin(w) + w*math.Sin(phi)*math.Sin(d))
+}
+
+//Gives the equation of time (minutes), the difference between the sun's
+//time and clock time caused by the Earth's tilt and orbit (Spencer).
+func EquationOfTime(day int) float64 {
+	b := radians(float64(day-1) * 360 / 365)
+	return 229.2 * (0.000075 + 0.001868*math.Cos(b) - 0.032077*math.Sin(b) -
+		0.014615*math.Cos(2*b) - 0.04089*math.Sin(2*b))
+}
+
+//Gives the hour angle of the sun (degrees, negative in the morning) at a
+//clock time in hours (local standard time, no daylight saving) for a
+//longitude (degrees, east positive) in a time zone (hours from UTC).
+func HourAngle(day int, clock, lon, timeZone float64) float64 {
+	solarTime := clock + (4*(lon-15*timeZone)+EquationOfTime(day))/60
+	return 15 * (solarTime - 12)
+}
+
+//Gives the cosine of the sun's zenith angle, the angle between the sun and
+//straight up. It is negative when the sun is down.
+func CosZenith(lat, decl, hourAngle float64) float64 {
+	phi, d, w := radians(lat), radians(decl), radians(hourAngle)
+	return math.Cos(phi)*math.Cos(d)*math.Cos(w) + math.Sin(phi)*math.Sin(d)
+}
+
+//Gives the extraterrestrial sunlight facing the sun on a day (W/m^2).
+func ExtraterrestrialNormal(day int) float64 {
+	return 1000 * SolarConstant * (1 + 0.033*math.Cos(radians(360*float64(day)/365)))
+}
@@ -0,0 +1,367 @@
+/*Description: This file holds electricity tariffs, the rules a utility uses
+to turn the energy a home takes from the grid into a bill: a fixed charge,
+energy rates that are flat, tiered or time of use, a demand charge, and the
+rule for the energy the panels send back to the grid. Every tariff is a
+JSON file in the tariffs directory. Bills are worked out hour by hour over
+a typical year, so the savings of the panels follow the real rates instead
+of one price per kwh.*/
+
+package solar
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//Ways a tariff pays for the energy the panels send to the grid.
+const (
+	NetMetering = "net-metering" //Exports are worth the retail rate; unused credit carries over to the next month
+	NetBilling  = "net-billing"  //Exports are worth ExportRate; unused credit carries over to the next month
+	NoExport    = "none"         //Exports are worth nothing
+)
+
+//ID of the tariff used for the cities no other tariff serves.
+const DefaultTariffID = "default"
+
+//This is one utility rate plan. The energy rate is Rate, unless Tiers are
+//given; time of use Periods change the rate for some hours of the day.
+type Tariff struct {
+	ID           string   `json:"id"`           //Name of the file without .json
+	Utility      string   `json:"utility"`      //Utility name
+	Name         string   `json:"name"`         //Name of the rate plan
+	Cities       []string `json:"cities"`       //Cities of the city data the utility serves
+	FixedCharge  float64  `json:"fixedCharge"`  //Charge every month (dollars)
+	Rate         float64  `json:"rate"`         //Energy rate (dollars per kwh), the off-peak rate with time of use periods
+	Tiers        []Tier   `json:"tiers"`        //Energy rates by the energy used in the month, in place of Rate
+	Periods      []Period `json:"periods"`      //Time of use energy rates; the hours outside of them pay Rate
+	DemandCharge float64  `json:"demandCharge"` //Charge for the most energy taken from the grid in one hour of the month (dollars per kw)
+	Export       string   `json:"export"`       //NetMetering (the default), NetBilling or NoExport
+	ExportRate   float64  `json:"exportRate"`   //Credit for exported energy with NetBilling (dollars per kwh)
+}
+
+//This is one tier of a tiered tariff. The energy of the month up to UpTo
+//that the tiers before it didn't cover pays Rate.
+type Tier struct {
+	UpTo float64 `json:"upTo"` //Energy of the month this tier ends at (kwh), 0 for the last tier
+	Rate float64 `json:"rate"` //Dollars per kwh
+}
+
+//This is one time of use period, such as the evening peak. Hours are solar
+//time, and the period applies every day of its months.
+type Period struct {
+	Name   string  `json:"name"`   //Name of the period, such as "peak"
+	Months []int   `json:"months"` //Months of the period (1 to 12), all of them if empty
+	Start  int     `json:"start"`  //First hour of the period (0 to 23)
+	End    int     `json:"end"`    //Hour the period ends (1 to 24), before Start for periods past midnight
+	Rate   float64 `json:"rate"`   //Dollars per kwh
+}
+
+//This is a bill for a typical year under one tariff.
+type Bill struct {
+	Fixed   float64     `json:"fixed"`   //Fixed charges (dollars)
+	Energy  float64     `json:"energy"`  //Energy charges (dollars)
+	Demand  float64     `json:"demand"`  //Demand charges (dollars)
+	Credit  float64     `json:"credit"`  //Credit for exported energy taken off the energy and demand charges (dollars)
+	Total   float64     `json:"total"`   //What is paid over the year (dollars)
+	Monthly [12]float64 `json:"monthly"` //What is paid in each month (dollars)
+}
+
+//Gives the tariff used when the tariffs directory has no default.json: 13
+//cents per kwh with net metering.
+func DefaultTariff() Tariff {
+	return Tariff{ID: DefaultTariffID, Name: "Flat rate", Rate: 0.13, Export: NetMetering}
+}
+
+//Gives a tariff with a flat rate (dollars per kwh) and net metering, for a
+//user who knows what they pay per kwh.
+func FlatTariff(rate float64) Tariff {
+	return Tariff{ID: "flat", Name: fmt.Sprintf("Flat rate of %g dollars per kwh", rate), Rate: rate, Export: NetMetering}
+}
+
+//Reads every .json file of the directory as a tariff, in the order of the
+//file names. A missing directory has no tariffs.
+func ReadTariffs(dir string) ([]Tariff, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	tariffs := make([]Tariff, 0, len(files))
+	for _, filename := range files {
+		tariff, err := ReadTariff(filename)
+		if err != nil {
+			return nil, err
+		}
+		tariffs = append(tariffs, tariff)
+	}
+	return tariffs, nil
+}
+
+//Reads and checks one tariff file. The ID is the file name without .json.
+func ReadTariff(filename string) (Tariff, error) {
+	var tariff Tariff
+	file, err := os.Open(filename)
+	if err != nil {
+		return tariff, err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields() //a misspelled field would otherwise be a rate of 0
+	if err := decoder.Decode(&tariff); err != nil {
+		return tariff, fmt.Errorf("%s: %v", filename, err)
+	}
+	tariff.ID = strings.TrimSuffix(filepath.Base(filename), ".json")
+	if tariff.Export == "" {
+		tariff.Export = NetMetering
+	}
+	if err := tariff.check(); err != nil {
+		return tariff, fmt.Errorf("%s: %v", filename, err)
+	}
+	return tariff, nil
+}
+
+//Checks that the rates can be used to work out a bill.
+func (t Tariff) check() error {
+	if t.Name == "" {
+		return errors.New("missing name")
+	}
+	if t.FixedCharge < 0 || t.Rate < 0 || t.DemandCharge < 0 || t.ExportRate < 0 {
+		return errors.New("charges and rates can't be negative")
+	}
+	if t.Export != NetMetering && t.Export != NetBilling && t.Export != NoExport {
+		return fmt.Errorf("export must be %q, %q or %q, not %q", NetMetering, NetBilling, NoExport, t.Export)
+	}
+	if len(t.Tiers) > 0 && len(t.Periods) > 0 {
+		return errors.New("a tariff can have tiers or time of use periods, not both")
+	}
+	var below float64
+	for i, tier := range t.Tiers {
+		last := i == len(t.Tiers)-1
+		switch {
+		case tier.Rate < 0:
+			return fmt.Errorf("tier %d: the rate can't be negative", i+1)
+		case last && tier.UpTo != 0:
+			return fmt.Errorf("tier %d: the last tier must have no upTo", i+1)
+		case !last && tier.UpTo <= below:
+			return fmt.Errorf("tier %d: upTo must be more than the tier before", i+1)
+		}
+		below = tier.UpTo
+	}
+	for i, period := range t.Periods {
+		switch {
+		case period.Rate < 0:
+			return fmt.Errorf("period %d: the rate can't be negative", i+1)
+		case period.Start < 0 || period.Start > 23:
+			return fmt.Errorf("period %d: start must be between 0 and 23", i+1)
+		case period.End < 1 || period.End > 24 || period.End == period.Start:
+			return fmt.Errorf("period %d: end must be between 1 and 24 and not the same as start", i+1)
+		}
+		for _, month := range period.Months {
+			if month < 1 || month > 12 {
+				return fmt.Errorf("period %d: months must be between 1 and 12", i+1)
+			}
+		}
+	}
+	return nil
+}
+
+//Reports whether the tariff lists the city among the ones it serves.
+func (t Tariff) Serves(city string) bool {
+	return contains(t.Cities, city)
+}
+
+//Gives the energy rate of an hour of the day (0 to 23) in a month (0 to
+//11). The first period covering the hour wins.
+func (t Tariff) hourRate(month, hour int) float64 {
+	for _, period := range t.Periods {
+		if period.covers(month, hour) {
+			return period.Rate
+		}
+	}
+	return t.Rate
+}
+
+//Reports whether the period covers an hour of the day in a month (0 to 11).
+func (p Period) covers(month, hour int) bool {
+	if len(p.Months) > 0 {
+		found := false
+		for _, m := range p.Months {
+			found = found || m == month+1
+		}
+		if !found {
+			return false
+		}
+	}
+	if p.Start < p.End {
+		return hour >= p.Start && hour < p.End
+	}
+	return hour >= p.Start || hour < p.End //past midnight
+}
+
+//Gives the energy charge of a month's energy with the tiers.
+func (t Tariff) tierCharge(kwh float64) float64 {
+	var charge, below float64
+	for _, tier := range t.Tiers {
+		if tier.UpTo == 0 || kwh <= tier.UpTo {
+			return charge + (kwh-below)*tier.Rate
+		}
+		charge += (tier.UpTo - below) * tier.Rate
+		below = tier.UpTo
+	}
+	return charge
+}
+
+//Works out the bill of a typical year. load is the energy the home uses in
+//each hour of the year and production the energy its panels make (kwh,
+//HoursPerYear values each); production is nil without panels. Export
+//credit pays the energy and demand charges, never the fixed charge, and
+//credit left at the end of the year is lost.
+func (t Tariff) Bill(load, production []float64) Bill {
+	var bill Bill
+	var banked float64 //credit carried over from the months before (dollars)
+	start := 0
+	for m := 0; m < 12; m++ {
+		end := start + int(MonthDays[m])*24
+		var imports, exports, peak float64    //kwh, and kw of the busiest hour
+		var importCharge, exportValue float64 //at the rates of the hours
+		for h := start; h < end && h < len(load); h++ {
+			net := load[h]
+			if production != nil {
+				net -= production[h]
+			}
+			rate := t.hourRate(m, h%24)
+			if net > 0 {
+				imports += net
+				importCharge += net * rate
+				peak = math.Max(peak, net)
+			} else {
+				exports -= net
+				exportValue -= net * rate
+			}
+		}
+		start = end
+
+		energy := importCharge
+		if len(t.Tiers) > 0 {
+			energy = t.tierCharge(imports)
+		}
+		var credit float64
+		switch t.Export {
+		case NetMetering:
+			credit = exportValue
+			if len(t.Tiers) > 0 { //the month's net energy goes through the tiers
+				energy = t.tierCharge(math.Max(0, imports-exports))
+				credit = math.Max(0, exports-imports) * t.Tiers[0].Rate
+			}
+		case NetBilling:
+			credit = exports * t.ExportRate
+		}
+		demand := peak * t.DemandCharge
+		credit += banked
+		used := math.Min(credit, energy+demand)
+		banked = credit - used
+
+		bill.Fixed += t.FixedCharge
+		bill.Energy += energy
+		bill.Demand += demand
+		bill.Credit += used
+		bill.Monthly[m] = float64(int((t.FixedCharge+energy+demand-used)*100)) / 100
+	}
+	bill.Total = bill.Fixed + bill.Energy + bill.Demand - bill.Credit
+	for _, value := range []*float64{&bill.Fixed, &bill.Energy, &bill.Demand, &bill.Credit, &bill.Total} {
+		*value = float64(int(*value*100)) / 100
+	}
+	return bill
+}
+
+//This is a home's electricity use over a typical year and the shape of its
+//panels' output, for comparing its bills with and without the panels.
+type BillModel struct {
+	Tariff Tariff
+	Load   []float64 //Energy the home uses in each hour of the year (kwh)
+	Shape  []float64 //Share of the year's solar output made in each hour, adding up to 1
+}
+
+//This is the yearly bill of a home without and with panels.
+type BillComparison struct {
+	Tariff  string  `json:"tariff"`  //ID of the tariff
+	Utility string  `json:"utility"` //Utility name
+	Name    string  `json:"name"`    //Name of the rate plan
+	Before  Bill    `json:"before"`  //Bill without panels
+	After   Bill    `json:"after"`   //Bill with panels
+	Savings float64 `json:"savings"` //What the panels save in a year (dollars)
+}
+
+//Gives the bills without panels and with panels making annual kwh in a year.
+func (b BillModel) Compare(annual float64) BillComparison {
+	production := make([]float64, len(b.Shape))
+	for h := range production {
+		production[h] = annual * b.Shape[h]
+	}
+	before := b.Tariff.Bill(b.Load, nil)
+	after := b.Tariff.Bill(b.Load, production)
+	return BillComparison{
+		Tariff:  b.Tariff.ID,
+		Utility: b.Tariff.Utility,
+		Name:    b.Tariff.Name,
+		Before:  before,
+		After:   after,
+		Savings: float64(int((before.Total-after.Total)*100)) / 100,
+	}
+}
+
+//Gives what a kwh saves on the bill (dollars per kwh) when the panels make
+//annual kwh in a year.
+func (b BillModel) Value(annual float64) float64 {
+	if annual <= 0 {
+		return 0
+	}
+	return b.Compare(annual).Savings / annual
+}
+
+//Gives the share of a year's solar output made in each hour of the year
+//(solar time) on a roof. The months follow the city's monthly sunlight on
+//the roof, and each day follows the sun: half of the light with the sun's
+//height (the sky) and half with its angle to the roof (the direct sun).
+func OutputShape(city City, roof Orientation) []float64 {
+	monthly := city.MonthlyOnRoof(roof)
+	lat := city.Latitude()
+	shape := make([]float64, 0, HoursPerYear)
+	var total float64
+	day := 1
+	for m := range monthly {
+		for d := 0; d < int(MonthDays[m]); d++ {
+			decl := Declination(day)
+			var hours [24]float64
+			var daily float64
+			for h := range hours {
+				hourAngle := 15 * (float64(h) + 0.5 - 12) //middle of the hour
+				if cosZenith := CosZenith(lat, decl, hourAngle); cosZenith > 0 {
+					hours[h] = (cosZenith + CosIncidence(lat, decl, hourAngle, roof)) / 2
+				}
+				daily += hours[h]
+			}
+			for h := range hours {
+				if daily > 0 {
+					hours[h] *= monthly[m] / daily
+				}
+				total += hours[h]
+				shape = append(shape, hours[h])
+			}
+			day++
+		}
+	}
+	if total > 0 {
+		for h := range shape {
+			shape[h] /= total
+		}
+	}
+	return shape
+}
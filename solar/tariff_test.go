@@ -0,0 +1,164 @@
+package solar
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+//Makes HoursPerYear values, kwh(month, hour) in every hour of the year
+//(month 0 to 11, hour of the day 0 to 23). The first day of each month is
+//day 1.
+func hourly(kwh func(month, day, hour int) float64) []float64 {
+	values := make([]float64, 0, HoursPerYear)
+	for m, days := range MonthDays {
+		for d := 1; d <= int(days); d++ {
+			for h := 0; h < 24; h++ {
+				values = append(values, kwh(m, d, h))
+			}
+		}
+	}
+	return values
+}
+
+//1 kwh every hour of the year.
+var steadyLoad = hourly(func(month, day, hour int) float64 { return 1 })
+
+//2 kwh in each hour from 10:00 to 14:00, twice the load: 4 kwh a day exported.
+var middayPanels = hourly(func(month, day, hour int) float64 {
+	if hour >= 10 && hour < 14 {
+		return 2
+	}
+	return 0
+})
+
+//Makes 3 kwh every hour of one month (0 to 11), 2 kwh an hour more than the load.
+func oneMonthPanels(month int) []float64 {
+	return hourly(func(m, day, hour int) float64 {
+		if m == month {
+			return 3
+		}
+		return 0
+	})
+}
+
+func TestTariffBill(t *testing.T) {
+	tiered := Tariff{Name: "tiered", Tiers: []Tier{{UpTo: 600, Rate: 0.10}, {Rate: 0.125}}, Export: NetMetering}
+	tests := []struct {
+		name       string
+		tariff     Tariff
+		load       []float64
+		production []float64
+		want       Bill //Total, Energy, Demand and Credit are checked, and the months that aren't 0
+	}{
+		//8760 kwh at 0.10, and 12 fixed charges.
+		{"flat", Tariff{Name: "flat", FixedCharge: 10, Rate: 0.1, Export: NetMetering}, steadyLoad, nil,
+			Bill{Fixed: 120, Energy: 876, Total: 996, Monthly: [12]float64{0: 84.4, 1: 77.2}}},
+		//600 kwh at 0.10 every month, then 144 kwh at 0.125 in a 31 day
+		//month, 72 in February and 120 in a 30 day month.
+		{"tiers", tiered, steadyLoad, nil,
+			Bill{Energy: 915, Total: 915, Monthly: [12]float64{0: 78, 1: 69, 3: 75}}},
+		//155 kwh of July's evenings at 0.50, the other 8605 kwh at 0.10.
+		{"time of use", Tariff{Name: "tou", Rate: 0.1, Periods: []Period{{Months: []int{7}, Start: 16, End: 21, Rate: 0.5}}, Export: NetMetering},
+			steadyLoad, nil, Bill{Energy: 938, Total: 938, Monthly: [12]float64{5: 72, 6: 136.4}}},
+		//8 hours a night at 0.05, the other 16 at 0.10.
+		{"time of use past midnight", Tariff{Name: "night", Rate: 0.1, Periods: []Period{{Start: 22, End: 6, Rate: 0.05}}, Export: NetMetering},
+			steadyLoad, nil, Bill{Energy: 730, Total: 730, Monthly: [12]float64{0: 62}}},
+		//One hour of 5 kwh on the first day of every month sets its peak.
+		{"demand charge", Tariff{Name: "demand", Rate: 0.1, DemandCharge: 8.5, Export: NetMetering},
+			hourly(func(month, day, hour int) float64 {
+				if day == 1 && hour == 18 {
+					return 5
+				}
+				return 1
+			}), nil, Bill{Energy: 880.8, Demand: 510, Total: 1390.8, Monthly: [12]float64{0: 117.3}}},
+
+		//20 kwh a day bought and 4 sold back at the same 0.10.
+		{"net metering", Tariff{Name: "nem", Rate: 0.1, Export: NetMetering}, steadyLoad, middayPanels,
+			Bill{Energy: 730, Credit: 146, Total: 584, Monthly: [12]float64{0: 49.6}}},
+		{"net billing", Tariff{Name: "nbt", Rate: 0.1, Export: NetBilling, ExportRate: 0.03}, steadyLoad, middayPanels,
+			Bill{Energy: 730, Credit: 43.8, Total: 686.2, Monthly: [12]float64{0: 58.28}}},
+		{"no export", Tariff{Name: "none", Rate: 0.1, Export: NoExport}, steadyLoad, middayPanels,
+			Bill{Energy: 730, Total: 730, Monthly: [12]float64{0: 62}}},
+		//Exports at the retail rate of their hour: the evening peak is worth more.
+		{"net metering with time of use", Tariff{Name: "nem tou", Rate: 0.1, Periods: []Period{{Start: 10, End: 12, Rate: 0.3}}, Export: NetMetering},
+			steadyLoad, middayPanels, Bill{Energy: 730, Credit: 292, Total: 438, Monthly: [12]float64{0: 37.2}}},
+
+		//January's 1488 kwh of credit, 148.80 dollars, pays February's 67.20,
+		//March's 74.40 and 7.20 of April's 72; never the fixed charge.
+		{"credit carried over", Tariff{Name: "carry", FixedCharge: 10, Rate: 0.1, Export: NetMetering}, steadyLoad, oneMonthPanels(0),
+			Bill{Fixed: 120, Energy: 801.6, Credit: 148.8, Total: 772.8, Monthly: [12]float64{0: 10, 1: 10, 2: 10, 3: 74.8, 4: 84.4}}},
+		//December's credit has no month after it to pay and is lost.
+		{"credit lost at the end of the year", Tariff{Name: "lost", FixedCharge: 10, Rate: 0.1, Export: NetMetering}, steadyLoad, oneMonthPanels(11),
+			Bill{Fixed: 120, Energy: 801.6, Total: 921.6, Monthly: [12]float64{0: 84.4, 11: 10}}},
+		//January's net 1488 kwh sent out is worth the first tier's 0.10 and
+		//pays February's 69 dollars of tiers, March's 78 and 1.80 of April's 75.
+		{"tiers with net metering", tiered, steadyLoad, oneMonthPanels(0),
+			Bill{Energy: 837, Credit: 148.8, Total: 688.2, Monthly: [12]float64{3: 73.2, 4: 78}}},
+	}
+	for _, test := range tests {
+		got := test.tariff.Bill(test.load, test.production)
+		checks := []struct {
+			name      string
+			got, want float64
+		}{
+			{"fixed", got.Fixed, test.want.Fixed},
+			{"energy", got.Energy, test.want.Energy},
+			{"demand", got.Demand, test.want.Demand},
+			{"credit", got.Credit, test.want.Credit},
+			{"total", got.Total, test.want.Total},
+		}
+		for m, want := range test.want.Monthly {
+			if want != 0 {
+				checks = append(checks, struct {
+					name      string
+					got, want float64
+				}{MonthNames[m], got.Monthly[m], want})
+			}
+		}
+		for _, check := range checks {
+			if math.Abs(check.got-check.want) > 0.011 { //the bill is cut to the cent
+				t.Errorf("%s: %s is %.2f, want %.2f", test.name, check.name, check.got, check.want)
+			}
+		}
+	}
+}
+
+func TestReadTariffs(t *testing.T) {
+	tariffs, err := ReadTariffs("testdata/tariffs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tariffs) != 4 || tariffs[0].ID != "example-arizona-demand" {
+		t.Fatalf("got %d tariffs starting with %q, want the 4 examples", len(tariffs), tariffs[0].ID)
+	}
+	if tariffs, err := ReadTariffs("testdata/missing"); err != nil || len(tariffs) != 0 {
+		t.Errorf("a missing directory: got %v, %v", tariffs, err)
+	}
+
+	tests := []struct {
+		name, json, err string
+	}{
+		{"default export", `{"name": "flat", "rate": 0.1}`, ""},
+		{"no name", `{"rate": 0.1}`, "missing name"},
+		{"misspelled field", `{"name": "flat", "rte": 0.1}`, "unknown field"},
+		{"negative rate", `{"name": "flat", "rate": -0.1}`, "can't be negative"},
+		{"bad export", `{"name": "flat", "rate": 0.1, "export": "gross"}`, "export must be"},
+		{"tiers and periods", `{"name": "both", "tiers": [{"rate": 0.1}], "periods": [{"start": 1, "end": 2, "rate": 0.2}]}`, "not both"},
+		{"last tier with upTo", `{"name": "tiers", "tiers": [{"upTo": 500, "rate": 0.1}]}`, "the last tier must have no upTo"},
+		{"tiers out of order", `{"name": "tiers", "tiers": [{"upTo": 500, "rate": 0.1}, {"upTo": 400, "rate": 0.2}, {"rate": 0.3}]}`, "tier 2"},
+		{"period ending at its start", `{"name": "tou", "rate": 0.1, "periods": [{"start": 5, "end": 5, "rate": 0.2}]}`, "period 1"},
+		{"period in month 13", `{"name": "tou", "rate": 0.1, "periods": [{"months": [13], "start": 5, "end": 9, "rate": 0.2}]}`, "months must be"},
+	}
+	for _, test := range tests {
+		tariff, err := ReadTariff(writeFile(t, "utility-plan.json", test.json))
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case test.err == "" && (tariff.ID != "utility-plan" || tariff.Export != NetMetering):
+			t.Errorf("%s: got ID %q and export %q", test.name, tariff.ID, tariff.Export)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s: got %v, want an error with %q", test.name, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+/*Description: This file lowers the expected output of panels in hot places
+and raises it in cold ones. Panels are rated at 25 Celsius and lose a
+little power for every degree they are hotter (the temperature
+coefficient); in the sun they run well above the air temperature (the
+NOCT).*/
+
+package solar
+
+import "math"
+
+//Converts Fahrenheit to Celsius.
+func FahrenheitToCelsius(f float64) float64 {
+	return (f - 32) * 5 / 9
+}
+
+//Gives the city's average air temperature (Celsius).
+func (c City) TempCelsius() float64 {
+	return FahrenheitToCelsius(c.Temp)
+}
+
+//Gives the average temperature of the panel's cells while it produces
+//power in the city, with radiation the daily sunlight on the panel
+//(kwh/m^2/day). The sunlight is spread over a 12 hour day, and weighted
+//towards the bright hours (pi^2/8 times the average for a sine shaped
+//day), since those make most of the energy.
+func PanelTemperature(city City, panel Panel, radiation float64) float64 {
+	sunlight := radiation * 1000 / 12 * math.Pi * math.Pi / 8 //W/m^2
+	return CellTemperature(city.TempCelsius(), sunlight, 1, panel.NOCT, panel.Efficiency/100)
+}
+
+//Gives the share of the rated output the panel makes at the city's
+//temperature, for example 0.93 in a hot place or 1.01 in a cold one.
+func TemperatureFactor(city City, panel Panel, radiation float64) float64 {
+	if radiation <= 0 {
+		return 1
+	}
+	change := panel.TempCoeff / 100 * (PanelTemperature(city, panel, radiation) - 25)
+	return math.Max(0, 1+change)
+}
+
+//Gives the percentage of energy lost to temperature for a factor from
+//TemperatureFactor, rounded to a tenth. It is negative when the cold adds
+//energy.
+func TempLoss(factor float64) float64 {
+	return float64(int((1-factor)*1000)) / 10
+}
+
+//Gives the average daily sunlight on a roof over the year (kwh/m^2/day).
+func RoofRadiation(city City, roof Orientation) float64 {
+	if roof.Flat() {
+		return city.SolarRad
+	}
+	var total float64
+	for m, radiation := range city.MonthlyOnRoof(roof) {
+		total += radiation * MonthDays[m]
+	}
+	return total / 365
+}
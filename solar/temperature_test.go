@@ -0,0 +1,48 @@
+package solar
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTemperatureFactor(t *testing.T) {
+	panel := Panel{Name: "Test", Efficiency: 18, NOCT: 45, TempCoeff: -0.4}
+	//With NOCT 45 and 18% efficiency the panel runs 25 * (1 - 0.18/0.9) = 20
+	//Celsius above the air in 800 W/m^2 of sunlight.
+	tests := []struct {
+		name      string
+		temp      float64 //the city's average temperature (Fahrenheit)
+		radiation float64
+		coeff     float64
+		cell      float64 //the panel's temperature (Celsius)
+		factor    float64
+		loss      float64
+	}{
+		//35 Celsius air. 6 kwh/m^2/day is 6000 / 12 * pi^2 / 8 = 616.85 W/m^2,
+		//so the panel is 616.85 / 800 * 20 = 15.42 hotter: 50.42 Celsius,
+		//25.42 above its rating, and loses 0.4% a degree.
+		{"hot", 95, 6, -0.4, 50.4213, 1 - 0.004*25.4213, 10.1},
+		//-5 Celsius air and 308.43 W/m^2: the panel is 7.71 hotter, 2.71
+		//Celsius, and 22.29 below its rating makes 8.9% more.
+		{"cold", 23, 3, -0.4, 2.7106, 1 + 0.004*22.2894, -8.9},
+		//60 Celsius air: 50.42 degrees over at 2% a degree is more than all of it.
+		{"no output left", 140, 6, -2, 75.4213, 0, 100},
+		{"no sunlight", 95, 0, -0.4, 35, 1, 0},
+		{"no coefficient", 95, 6, 0, 50.4213, 1, 0},
+	}
+	for _, test := range tests {
+		city := City{Name: test.name, Temp: test.temp}
+		panel.TempCoeff = test.coeff
+		cell := PanelTemperature(city, panel, test.radiation)
+		factor := TemperatureFactor(city, panel, test.radiation)
+		if math.Abs(cell-test.cell) > 1e-3 || math.Abs(factor-test.factor) > 1e-5 || TempLoss(factor) != test.loss {
+			t.Errorf("%s: got %g Celsius, a factor of %g and a loss of %g%%; want %g, %g and %g%%",
+				test.name, cell, factor, TempLoss(factor), test.cell, test.factor, test.loss)
+		}
+	}
+
+	//The loss is truncated to a tenth, towards 0 when it is negative.
+	if TempLoss(0.9345) != 6.5 || TempLoss(1.0123) != -1.2 || TempLoss(1) != 0 {
+		t.Errorf("got %g, %g and %g, want 6.5, -1.2 and 0", TempLoss(0.9345), TempLoss(1.0123), TempLoss(1))
+	}
+}
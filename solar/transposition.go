@@ -0,0 +1,145 @@
+/*Description: This file works out how much sunlight reaches a roof with a
+given pitch and compass direction, from the solar radiation on a flat
+surface. The sunlight is split into the direct beam from the sun and the
+diffuse light from the rest of the sky, and each is turned onto the roof
+with the Hay-Davies model. Equations are from Duffie and Beckman, Solar
+Engineering of Thermal Processes.*/
+
+package solar
+
+import "math"
+
+//Share of the sunlight the ground reflects onto the roof.
+const GroundAlbedo = 0.2
+
+//Orientation is the direction a roof (and the panels on it) faces. The
+//zero Orientation is a flat roof.
+type Orientation struct {
+	Tilt    float64 `json:"tilt"`    //Roof pitch (degrees from horizontal, 0 to 90)
+	Azimuth float64 `json:"azimuth"` //Compass direction the roof faces (degrees, 90 east, 180 south, 270 west)
+}
+
+//Reports whether the roof is flat, so the orientation doesn't matter.
+func (o Orientation) Flat() bool {
+	return o.Tilt == 0
+}
+
+//Gives the share of a day's sunlight on a flat surface that is diffuse
+//light from the sky, from the share of the extraterrestrial sunlight that
+//gets through the atmosphere (monthly average correlation of Erbs).
+func DiffuseFraction(clearness, sunsetAngle float64) float64 {
+	k := math.Max(0.3, math.Min(0.8, clearness)) //the range the correlation was fitted on
+	if sunsetAngle <= 81.4 {
+		return 1.391 - 3.560*k + 4.189*k*k - 2.137*k*k*k
+	}
+	return 1.311 - 3.022*k + 3.427*k*k - 1.821*k*k*k
+}
+
+//Gives the average daily sunlight on a roof in a month (kwh/m^2/day),
+//from the average daily sunlight on a flat surface (ghi) at a latitude.
+//The day is the month's representative day, split into short steps; in
+//each step the flat surface sunlight is spread over the day with the
+//Collares-Pereira and Rabl (total) and Liu and Jordan (diffuse) profiles.
+func PlaneOfArray(lat float64, month int, ghi float64, roof Orientation) float64 {
+	if roof.Flat() || ghi <= 0 {
+		return ghi
+	}
+	day := RepresentativeDays[month]
+	decl := Declination(day)
+	ws := SunsetHourAngle(lat, decl)
+	extraterrestrial := DailyExtraterrestrial(lat, day)
+	if ws <= 0 || extraterrestrial <= 0 {
+		return 0 //polar night
+	}
+	diffuse := ghi * DiffuseFraction(ghi/extraterrestrial, ws)
+
+	wsRad := radians(ws)
+	a := 0.409 + 0.5016*math.Sin(wsRad-math.Pi/3)
+	b := 0.6609 - 0.4767*math.Sin(wsRad-math.Pi/3)
+	normal := ExtraterrestrialNormal(day) / 1000 //kW/m^2, like the sunlight below
+	denominator := math.Sin(wsRad) - wsRad*math.Cos(wsRad)
+
+	const steps = 96
+	step := 2 * ws / steps //degrees of hour angle
+	var total float64
+	for i := 0; i < steps; i++ {
+		w := -ws + (float64(i)+0.5)*step
+		cosZenith := CosZenith(lat, decl, w)
+		if cosZenith <= 0.01 {
+			continue //sun on the horizon
+		}
+		//sunlight on a flat surface at this time (kW/m^2)
+		cosW := math.Cos(radians(w)) - math.Cos(wsRad)
+		global := ghi * math.Pi / 24 * (a + b*math.Cos(radians(w))) * cosW / denominator
+		diffuseNow := diffuse * math.Pi / 24 * cosW / denominator
+		beam := math.Max(0, global-diffuseNow) / cosZenith
+
+		onRoof := HourlyPlaneOfArray(global, beam, diffuseNow, cosZenith, CosIncidence(lat, decl, w, roof), normal, roof)
+		total += onRoof * step / 15 //15 degrees of hour angle per hour
+	}
+	return total
+}
+
+//Gives the cosine of the angle between the sun and the roof's perpendicular
+//at an hour angle (degrees, negative in the morning), or 0 when the sun is
+//behind the roof.
+func CosIncidence(lat, decl, hourAngle float64, roof Orientation) float64 {
+	phi, d, w := radians(lat), radians(decl), radians(hourAngle)
+	beta, gamma := radians(roof.Tilt), radians(roof.Azimuth-180) //gamma is 0 facing south, negative east
+	cosIncidence := math.Sin(d)*math.Sin(phi)*math.Cos(beta) -
+		math.Sin(d)*math.Cos(phi)*math.Sin(beta)*math.Cos(gamma) +
+		math.Cos(d)*math.Cos(phi)*math.Cos(beta)*math.Cos(w) +
+		math.Cos(d)*math.Sin(phi)*math.Sin(beta)*math.Cos(gamma)*math.Cos(w) +
+		math.Cos(d)*math.Sin(beta)*math.Sin(gamma)*math.Sin(w)
+	return math.Max(0, cosIncidence)
+}
+
+//Gives the sunlight on a roof (W/m^2) from the three parts of the sunlight
+//measured by a weather station: global horizontal (ghi), direct normal
+//(dni) and diffuse horizontal (dhi). normal is the extraterrestrial
+//sunlight facing the sun (W/m^2), which sets how much of the diffuse light
+//comes from around the sun (Hay-Davies).
+func HourlyPlaneOfArray(ghi, dni, dhi, cosZenith, cosIncidence, normal float64, roof Orientation) float64 {
+	beta := radians(roof.Tilt)
+	var beamRatio, anisotropy float64
+	if cosZenith > 0 {
+		beamRatio = cosIncidence / math.Max(cosZenith, 0.087) //no bigger than with the sun 5 degrees up
+		if normal > 0 {
+			anisotropy = math.Min(1, dni/normal)
+		}
+	} else {
+		dni = 0 //the sun is down; only the sky light is left
+	}
+	return dni*cosIncidence +
+		dhi*(anisotropy*beamRatio+(1-anisotropy)*(1+math.Cos(beta))/2) +
+		ghi*GroundAlbedo*(1-math.Cos(beta))/2
+}
+
+//Gives the city's 12 monthly solar radiation values on a roof (kwh/m^2/day).
+func (c City) MonthlyOnRoof(roof Orientation) [12]float64 {
+	radiation := c.Monthly()
+	for m := range radiation {
+		radiation[m] = PlaneOfArray(c.Latitude(), m, radiation[m], roof)
+	}
+	return radiation
+}
+
+//Calculates the expected generated energy from solar panels on a roof with
+//the given pitch and direction, like SolarOutput. (in kwh per month) A flat
+//roof gives the same number as SolarOutput with "horizontal".
+func RoofOutput(city City, roof Orientation, efficiency, roofSize float64, losses Losses) float64 {
+	if roof.Flat() {
+		return SolarOutput(city, "horizontal", efficiency, roofSize, losses)
+	}
+	var output float64
+	for _, monthly := range MonthlyRoofOutput(city, roof, efficiency, roofSize, losses) {
+		output += monthly
+	}
+	return output / 12
+}
+
+//Calculates the expected generated energy from solar panels on a roof with
+//the given pitch and direction in each month (kwh).
+func MonthlyRoofOutput(city City, roof Orientation, efficiency, roofSize float64, losses Losses) [12]float64 {
+	return monthlyEnergy(city.MonthlyOnRoof(roof), efficiency, roofSize, losses)
+}
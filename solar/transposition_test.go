@@ -0,0 +1,110 @@
+package solar
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHourlyPlaneOfArray(t *testing.T) {
+	tests := []struct {
+		name                                           string
+		ghi, dni, dhi, cosZenith, cosIncidence, normal float64
+		roof                                           Orientation
+		want                                           float64
+	}{
+		//A flat roof gets the sunlight on a flat surface: 800*0.5 + 100.
+		{"flat", 500, 800, 100, 0.5, 0.5, 1367, Orientation{}, 500},
+		//Beam 800*0.6 = 480; the sky is 80% around the sun (800/1000):
+		//100*(0.8*0.6/0.5 + 0.2*(1+cos 90°)/2) = 106; the ground 500*0.2*(1-cos 90°)/2 = 50.
+		{"wall facing the sun", 500, 800, 100, 0.5, 0.6, 1000, Orientation{90, 180}, 636},
+		//No beam and no light from around the sun: 100*0.2*(1+cos 30°)/2 + 500*0.2*(1-cos 30°)/2.
+		{"sun behind the roof", 500, 800, 100, 0.5, 0, 1000, Orientation{30, 0}, 25.358983848622},
+		//The sun is down: only the sky, 20*(1+cos 90°)/2, and the ground, 20*0.2/2.
+		{"sun down", 20, 50, 20, -0.1, 0, 1000, Orientation{90, 180}, 12},
+		//The sun 0.6° up: the ratio of the light from around the sun is
+		//0.5/0.087 instead of 0.5/0.01.
+		{"sun on the horizon", 51, 100, 50, 0.01, 0.5, 1000, Orientation{45, 90}, 118.639290176554},
+	}
+	for _, test := range tests {
+		got := HourlyPlaneOfArray(test.ghi, test.dni, test.dhi, test.cosZenith, test.cosIncidence, test.normal, test.roof)
+		if math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%s: got %.12f W/m^2, want %.12f", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDiffuseFraction(t *testing.T) {
+	tests := []struct {
+		clearness, sunsetAngle, want float64
+	}{
+		{0.5, 80, 0.391125},
+		{0.5, 90, 0.429125},
+		{0.1, 80, DiffuseFraction(0.3, 80)}, //outside the range of the fit
+		{0.95, 90, DiffuseFraction(0.8, 90)},
+	}
+	for _, test := range tests {
+		if got := DiffuseFraction(test.clearness, test.sunsetAngle); math.Abs(got-test.want) > 1e-12 {
+			t.Errorf("DiffuseFraction(%g, %g) = %g, want %g", test.clearness, test.sunsetAngle, got, test.want)
+		}
+	}
+}
+
+func TestCosIncidence(t *testing.T) {
+	//At noon on an equinox the sun is straight in front of a south roof
+	//tilted at the latitude.
+	if got := CosIncidence(40, 0, 0, Orientation{40, 180}); math.Abs(got-1) > 1e-12 {
+		t.Errorf("roof tilted at the latitude: got %g, want 1", got)
+	}
+	//A flat roof sees the sun at its zenith angle.
+	for _, w := range []float64{-60, -15, 0, 30, 75} {
+		if got, want := CosIncidence(35, 10, w, Orientation{}), CosZenith(35, 10, w); math.Abs(got-want) > 1e-12 {
+			t.Errorf("flat roof at %g°: got %g, want %g", w, got, want)
+		}
+	}
+	//In the morning the sun is behind a roof facing west.
+	if got := CosIncidence(35, 0, -60, Orientation{60, 270}); got != 0 {
+		t.Errorf("west roof in the morning: got %g, want 0", got)
+	}
+}
+
+func TestPlaneOfArray(t *testing.T) {
+	const lat = 40.0
+	if got := PlaneOfArray(lat, 0, 2.5, Orientation{}); got != 2.5 {
+		t.Errorf("flat roof: got %g, want the flat surface's 2.5", got)
+	}
+	//In December a steep south roof catches more of the low sun than the
+	//ground, and a north roof less.
+	south := PlaneOfArray(lat, 11, 2, Orientation{40, 180})
+	north := PlaneOfArray(lat, 11, 2, Orientation{40, 0})
+	if south <= 2 || north >= 2 {
+		t.Errorf("December: south %g, north %g, want more and less than 2", south, north)
+	}
+	//East and west roofs see the mirror image of the same day.
+	east := PlaneOfArray(lat, 5, 6, Orientation{30, 90})
+	west := PlaneOfArray(lat, 5, 6, Orientation{30, 270})
+	if math.Abs(east-west) > 1e-9 {
+		t.Errorf("June: east %g, west %g, want the same", east, west)
+	}
+	if got := PlaneOfArray(80, 11, 0.1, Orientation{30, 180}); got != 0 {
+		t.Errorf("polar night: got %g, want 0", got)
+	}
+}
+
+func TestRoofOutput(t *testing.T) {
+	city := City{CoordN: 42.36, SolarRad: 3.9}
+	losses := DefaultLosses()
+	if got, want := RoofOutput(city, Orientation{}, 16, 1000, losses), SolarOutput(city, "horizontal", 16, 1000, losses); got != want {
+		t.Errorf("flat roof: got %g, want SolarOutput's %g", got, want)
+	}
+	roof := Orientation{30, 200}
+	var total float64
+	for _, month := range MonthlyRoofOutput(city, roof, 16, 1000, losses) {
+		total += month
+	}
+	if got := RoofOutput(city, roof, 16, 1000, losses); math.Abs(got-total/12) > 1e-9 {
+		t.Errorf("sloped roof: got %g, want the average month %g", got, total/12)
+	}
+	if got := RoofOutput(city, roof, 16, 1000, losses); got <= SolarOutput(city, "horizontal", 16, 1000, losses) {
+		t.Errorf("a roof facing south south west at 30° made %g, no more than a flat one", got)
+	}
+}
@@ -0,0 +1,153 @@
+/*Description: This file holds the electricity a home used, as read by its
+meter or billed by its utility. Readings rarely cover every hour of a
+year, so the hours without one are filled in from the load profile of the
+home's city, scaled to the hours that were read.*/
+
+package solar
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+//Values of a typical year, one for each hour or month. A value that
+//wasn't read is NaN, written as null in JSON.
+type Readings []float64
+
+//Writes the readings as a JSON list, with null for the missing ones.
+func (r Readings) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, value := range r {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if math.IsNaN(value) {
+			buf.WriteString("null")
+		} else {
+			buf.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+		}
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+//Reads a JSON list of readings, with null for the missing ones.
+func (r *Readings) UnmarshalJSON(data []byte) error {
+	var values []*float64
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	*r = make(Readings, len(values))
+	for i, value := range values {
+		(*r)[i] = math.NaN()
+		if value != nil {
+			(*r)[i] = *value
+		}
+	}
+	return nil
+}
+
+//Where the usage of a home comes from.
+const (
+	MeterReadings = "meter" //Hourly or shorter readings, such as a Green Button file
+	UtilityBills  = "bills" //Monthly bills
+)
+
+//This is the electricity a home used over a typical year, from its meter
+//readings or its bills.
+type Usage struct {
+	Source  string        `json:"source,omitempty"` //MeterReadings or UtilityBills
+	From    string        `json:"from,omitempty"`   //First day of the readings (YYYY-MM-DD)
+	To      string        `json:"to,omitempty"`     //Last day of the readings (YYYY-MM-DD)
+	Hours   int           `json:"hours"`            //Hours of the year that were read
+	Monthly Readings      `json:"monthly"`          //Energy used in each month (kwh), from the hours of the month that were read; null for months without readings
+	Hourly  Readings      `json:"hourly,omitempty"` //Energy used in each hour of the year (kwh, HoursPerYear values from 1 January 0:00); null for hours without readings
+	Bills   []UtilityBill `json:"bills,omitempty"`  //The bills, for UtilityBills
+	Cost    float64       `json:"cost,omitempty"`   //What the bills cost in all (dollars)
+	Price   float64       `json:"price,omitempty"`  //What a kwh cost on the bills (dollars per kwh)
+}
+
+//Makes the usage of hourly readings (kwh, HoursPerYear values); readings
+//of more than a year are averaged into one year first. At least one hour
+//must be read and none can be negative.
+func NewUsage(hourly Readings) (Usage, error) {
+	usage := Usage{Source: MeterReadings, Hourly: hourly, Monthly: make(Readings, 12)}
+	if len(hourly) != HoursPerYear {
+		return usage, fmt.Errorf("expected %d hourly values, found %d", HoursPerYear, len(hourly))
+	}
+	start := 0
+	for m := range usage.Monthly {
+		end := start + int(MonthDays[m])*24
+		var total float64
+		var hours int
+		for h := start; h < end; h++ {
+			switch {
+			case math.IsNaN(hourly[h]):
+				continue
+			case hourly[h] < 0 || math.IsInf(hourly[h], 0):
+				return usage, fmt.Errorf("hour %d: usage can't be %g", h, hourly[h])
+			}
+			total += hourly[h]
+			hours++
+		}
+		usage.Monthly[m] = math.NaN()
+		if hours > 0 {
+			usage.Monthly[m] = float64(int(total/float64(hours)*float64(end-start)*100)) / 100
+		}
+		usage.Hours += hours
+		start = end
+	}
+	if usage.Hours == 0 {
+		return usage, errors.New("no hour of the year was read")
+	}
+	return usage, nil
+}
+
+//Gives the hour of the year of t (0 is 1 January 0:00). 29 February is
+//counted as 28 February.
+func hourOfYear(t time.Time) int {
+	day := t.YearDay() - 1
+	if year := t.Year(); day >= 59 && year%4 == 0 && (year%100 != 0 || year%400 == 0) {
+		day--
+	}
+	return day*24 + t.Hour()
+}
+
+//Gives the energy used in each hour of the year (kwh, HoursPerYear values)
+//by the home, with baseline the LoadProfile of a home of its size using
+//the city's average energy. The hours that were read keep their readings;
+//the energy of a bill is spread over its hours like the baseline. The
+//other hours follow the baseline, scaled so that over the hours that were
+//read it used as much as the home did.
+func (u Usage) Profile(baseline []float64) []float64 {
+	hourly := u.Hourly
+	if len(u.Bills) > 0 {
+		hourly = billHours(u.Bills, baseline)
+	}
+	var read, modeled float64
+	for h, kwh := range hourly {
+		if !math.IsNaN(kwh) {
+			read += kwh
+			modeled += baseline[h]
+		}
+	}
+	scale := 1.0
+	if modeled > 0 {
+		scale = read / modeled
+	}
+	load := make([]float64, HoursPerYear)
+	for h := range load {
+		if h < len(hourly) && !math.IsNaN(hourly[h]) {
+			load[h] = hourly[h]
+		} else {
+			load[h] = baseline[h] * scale
+		}
+	}
+	return load
+}
@@ -0,0 +1,181 @@
+/*Description: This file reads a home's utility bills, a csv file with one
+bill per line: the first and last day of the billing period, the kwh used
+and what the bill cost. The energy of every bill is spread over the hours
+of its billing period, which gives the home's Usage.*/
+
+package solar
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+//Longest billing period (days).
+const MaxBillDays = 93
+
+//This is one utility bill.
+type UtilityBill struct {
+	Start string  `json:"start"` //First day of the billing period (YYYY-MM-DD)
+	End   string  `json:"end"`   //Last day of the billing period (YYYY-MM-DD)
+	Kwh   float64 `json:"kwh"`   //Energy used in the billing period (kwh)
+	Cost  float64 `json:"cost"`  //What the bill cost (dollars)
+}
+
+//Reads the csv file of a home's bills and gives its usage. A first line
+//that doesn't start with a date names the columns and is skipped, and the
+//cost may start with a dollar sign.
+func ReadUtilityBills(r io.Reader) (Usage, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	bills := make([]UtilityBill, 0)
+	line := 0
+	for {
+		items, err := reader.Read()
+		line++
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Usage{}, err
+		}
+		if BlankLine(items) {
+			continue
+		}
+		items[0] = strings.TrimPrefix(items[0], "\ufeff") //the byte order mark of spreadsheet exports
+		if _, err := time.Parse(DateLayout, strings.TrimSpace(items[0])); line == 1 && err != nil {
+			continue //the names of the columns
+		}
+		bill, err := MakeUtilityBill(items)
+		if err != nil {
+			return Usage{}, lineError(err, "bills", line)
+		}
+		bills = append(bills, bill)
+	}
+	return NewBillUsage(bills)
+}
+
+//Make a UtilityBill object using UtilityBill struct.
+func MakeUtilityBill(items []string) (UtilityBill, error) {
+	var bill UtilityBill
+	var err error
+	if len(items) < 4 {
+		return bill, fmt.Errorf("expected 4 columns (start, end, kwh, cost), found %d", len(items))
+	}
+	bill.Start = strings.TrimSpace(items[0])
+	bill.End = strings.TrimSpace(items[1])
+	items[3] = strings.TrimPrefix(strings.TrimSpace(items[3]), "$")
+	if bill.Kwh, err = ParseColumn(items, 2); err != nil {
+		return bill, err
+	}
+	if bill.Cost, err = ParseColumn(items, 3); err != nil {
+		return bill, err
+	}
+	return bill, bill.check()
+}
+
+//Checks the billing period and that the energy and cost aren't negative.
+func (b UtilityBill) check() error {
+	start, err := time.Parse(DateLayout, b.Start)
+	if err != nil {
+		return fmt.Errorf("start %q must be written as YYYY-MM-DD", b.Start)
+	}
+	end, err := time.Parse(DateLayout, b.End)
+	if err != nil {
+		return fmt.Errorf("end %q must be written as YYYY-MM-DD", b.End)
+	}
+	switch days := end.Sub(start).Hours()/24 + 1; {
+	case days < 1:
+		return errors.New("the billing period ends before it starts")
+	case days > MaxBillDays:
+		return fmt.Errorf("the billing period can't be longer than %d days", MaxBillDays)
+	}
+	if b.Kwh < 0 || b.Cost < 0 || math.IsNaN(b.Kwh+b.Cost) || math.IsInf(b.Kwh+b.Cost, 0) {
+		return errors.New("the kwh and cost can't be negative")
+	}
+	return nil
+}
+
+//Makes the usage of a home's bills. Until the city is known, the hours of
+//a bill share its energy evenly; Usage.Profile spreads it like the city's
+//load profile.
+func NewBillUsage(bills []UtilityBill) (Usage, error) {
+	if len(bills) == 0 {
+		return Usage{}, errors.New("there are no bills")
+	}
+	if len(bills) > MaxReadingYears*12 {
+		return Usage{}, fmt.Errorf("the bills cover more than %d years", MaxReadingYears)
+	}
+	var kwh, cost float64
+	from, to := bills[0].Start, bills[0].End
+	for i, bill := range bills {
+		if err := bill.check(); err != nil {
+			return Usage{}, fmt.Errorf("bill %d: %v", i+1, err)
+		}
+		kwh += bill.Kwh
+		cost += bill.Cost
+		if bill.Start < from { //dates written as YYYY-MM-DD sort like the days
+			from = bill.Start
+		}
+		if bill.End > to {
+			to = bill.End
+		}
+	}
+	usage, err := NewUsage(billHours(bills, nil))
+	if err != nil {
+		return usage, err
+	}
+	usage.Source, usage.Hourly, usage.Bills = UtilityBills, nil, bills
+	usage.From, usage.To = from, to
+	usage.Cost = float64(int(cost*100)) / 100
+	if kwh > 0 {
+		usage.Price = float64(int(cost/kwh*1000)) / 1000
+	}
+	return usage, nil
+}
+
+//Spreads the energy of every bill over the hours of its billing period,
+//in proportion to shape (HoursPerYear values), or evenly if shape is nil.
+//An hour billed more than once gets the average, and hours that weren't
+//billed are NaN. The bills must have been checked.
+func billHours(bills []UtilityBill, shape []float64) Readings {
+	var energy, billed [HoursPerYear]float64
+	for _, bill := range bills {
+		start, _ := time.Parse(DateLayout, bill.Start)
+		end, _ := time.Parse(DateLayout, bill.End)
+		hours := make([]int, 0, MaxBillDays*24)
+		for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+			for hour := hourOfYear(day); hour < hourOfYear(day)+24; hour++ {
+				hours = append(hours, hour)
+			}
+		}
+		weights := make([]float64, len(hours))
+		var total float64
+		for i, h := range hours {
+			weights[i] = 1
+			if shape != nil {
+				weights[i] = shape[h]
+			}
+			total += weights[i]
+		}
+		for i, h := range hours {
+			if total > 0 {
+				energy[h] += bill.Kwh * weights[i] / total
+			}
+			billed[h]++
+		}
+	}
+	hourly := make(Readings, HoursPerYear)
+	for h := range hourly {
+		hourly[h] = math.NaN()
+		if billed[h] > 0 {
+			hourly[h] = energy[h] / billed[h]
+		}
+	}
+	return hourly
+}
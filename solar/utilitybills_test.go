@@ -0,0 +1,109 @@
+package solar
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestReadUtilityBills(t *testing.T) {
+	//A spreadsheet export: byte order mark, column names, dollar signs and a blank line.
+	csv := "\ufeffStart,End,kWh,Cost\n2023-01-01,2023-01-31,744,$100.00\n\n2023-02-01, 2023-02-28, 1344, 150\n"
+	usage, err := ReadUtilityBills(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Source != UtilityBills || len(usage.Bills) != 2 || usage.Hourly != nil || usage.From != "2023-01-01" || usage.To != "2023-02-28" {
+		t.Errorf("got %s usage of %d bills from %s to %s", usage.Source, len(usage.Bills), usage.From, usage.To)
+	}
+	//250 dollars for 2088 kwh.
+	if usage.Hours != 744+672 || usage.Monthly[0] != 744 || usage.Monthly[1] != 1344 || usage.Cost != 250 || usage.Price != 0.119 {
+		t.Errorf("got %d hours, %g and %g kwh in January and February, %g dollars at %g a kwh; want 1416, 744, 1344, 250 and 0.119",
+			usage.Hours, usage.Monthly[0], usage.Monthly[1], usage.Cost, usage.Price)
+	}
+
+	tests := []struct {
+		name, csv, err string
+	}{
+		{"no bills", "start,end,kwh,cost\n", "there are no bills"},
+		{"three columns", "2023-01-01,2023-01-31,744\n", "bills:1: expected 4 columns"},
+		{"kwh not a number", "start,end,kwh,cost\n2023-01-01,2023-01-31,lots,100\n", "bills:2: column 3"},
+		{"cost not a number", "2023-01-01,2023-01-31,744,free\n", "bills:1: column 4"},
+		{"date", "start,end,kwh,cost\n01/01/2023,01/31/2023,744,100\n", "bills:2: start \"01/01/2023\" must be written as YYYY-MM-DD"},
+		{"ends before it starts", "2023-01-31,2023-01-01,744,100\n", "ends before it starts"},
+		{"a year in one bill", "2023-01-01,2023-12-31,9000,1000\n", "longer than 93 days"},
+		{"negative", "2023-01-01,2023-01-31,-744,100\n", "can't be negative"},
+	}
+	for _, test := range tests {
+		_, err := ReadUtilityBills(strings.NewReader(test.csv))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got %v, want an error with %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestNewBillUsage(t *testing.T) {
+	bill := UtilityBill{Start: "2023-01-01", End: "2023-01-31", Kwh: 744, Cost: 100}
+	if _, err := NewBillUsage([]UtilityBill{bill, {Start: "2023-02-01", End: "2023-01-28"}}); err == nil || !strings.Contains(err.Error(), "bill 2:") {
+		t.Errorf("a bad second bill: got %v", err)
+	}
+	if _, err := NewBillUsage(make([]UtilityBill, MaxReadingYears*12+1)); err == nil || !strings.Contains(err.Error(), "more than 10 years") {
+		t.Errorf("121 bills: got %v", err)
+	}
+	//The same January billed in two years is averaged.
+	usage, err := NewBillUsage([]UtilityBill{bill, {Start: "2024-01-01", End: "2024-01-31", Kwh: 2232, Cost: 300}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Monthly[0] != 1488 || usage.Hours != 744 || usage.From != "2023-01-01" || usage.To != "2024-01-31" {
+		t.Errorf("got %g kwh in %d hours from %s to %s, want 1488 in 744 from 2023-01-01 to 2024-01-31",
+			usage.Monthly[0], usage.Hours, usage.From, usage.To)
+	}
+}
+
+func TestBillHours(t *testing.T) {
+	twoDays := UtilityBill{Start: "2023-01-01", End: "2023-01-02", Kwh: 48}
+	tests := []struct {
+		name  string
+		bills []UtilityBill
+		shape []float64
+		want  map[int]float64 //kwh of some hours
+	}{
+		{"even", []UtilityBill{twoDays}, nil, map[int]float64{0: 1, 47: 1}},
+		//The shape's 3 in the first hour and 1 in the other 47 add up to 50.
+		{"shaped", []UtilityBill{twoDays}, hourly(func(month, day, hour int) float64 {
+			if day == 1 && hour == 0 && month == 0 {
+				return 3
+			}
+			return 1
+		}), map[int]float64{0: 2.88, 1: 0.96, 47: 0.96}},
+		//1 January is in both bills: 1 and 2 kwh an hour.
+		{"billed twice", []UtilityBill{{Start: "2023-01-01", End: "2023-01-01", Kwh: 24}, {Start: "2023-01-01", End: "2023-01-02", Kwh: 96}}, nil,
+			map[int]float64{0: 1.5, 24: 2}},
+		//29 February is counted as 28 February.
+		{"leap day", []UtilityBill{{Start: "2024-02-28", End: "2024-02-29", Kwh: 48}}, nil, map[int]float64{58 * 24: 1}},
+	}
+	for _, test := range tests {
+		hours := billHours(test.bills, test.shape)
+		for h, want := range test.want {
+			if math.Abs(hours[h]-want) > 1e-9 {
+				t.Errorf("%s: hour %d is %g kwh, want %g", test.name, h, hours[h], want)
+			}
+		}
+		if !math.IsNaN(hours[48]) {
+			t.Errorf("%s: hour 48 wasn't billed but is %g kwh", test.name, hours[48])
+		}
+	}
+
+	//Usage.Profile spreads the bills like the baseline and scales the
+	//hours that weren't billed: 48 kwh where the baseline has 100.
+	baseline := make([]float64, HoursPerYear)
+	for h := range baseline {
+		baseline[h] = 2
+	}
+	baseline[0] = 6
+	load := Usage{Bills: []UtilityBill{twoDays}}.Profile(baseline)
+	if math.Abs(load[0]-2.88) > 1e-9 || math.Abs(load[1]-0.96) > 1e-9 || math.Abs(load[48]-0.96) > 1e-9 {
+		t.Errorf("got %g, %g and %g kwh, want 2.88, 0.96 and 0.96", load[0], load[1], load[48])
+	}
+}
@@ -0,0 +1,245 @@
+/*Description: This file reads Typical Meteorological Year weather files,
+which hold one typical year of hourly sunlight and temperature for a
+weather station. Both the TMY3 csv format of the National Solar Radiation
+Database and the EnergyPlus EPW format are read.*/
+
+package solar
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//Number of hours in a typical year.
+const HoursPerYear = 8760
+
+//This is one typical year of hourly weather at a station.
+type Weather struct {
+	Station   string        //Station name
+	Latitude  float64       //Degrees, north is positive
+	Longitude float64       //Degrees, east is positive
+	TimeZone  float64       //Hours from UTC of the times in Hours (standard time)
+	Hours     []WeatherHour //HoursPerYear hours, starting at 1 January 0:00
+}
+
+//This is the weather of one hour. The sunlight values are averages over
+//the hour (W/m^2), which is the same as the energy of the hour (Wh/m^2).
+type WeatherHour struct {
+	Month int     //1 to 12
+	Day   int     //Day of the month
+	Hour  int     //1 to 24, the hour ending at this time
+	GHI   float64 //Global horizontal sunlight (W/m^2)
+	DNI   float64 //Direct normal sunlight (W/m^2)
+	DHI   float64 //Diffuse horizontal sunlight (W/m^2)
+	Temp  float64 //Air temperature (Celsius)
+	Wind  float64 //Wind speed (m/s)
+}
+
+//Gives the day of the year of the hour, 1 to 365.
+func (h WeatherHour) DayOfYear() int {
+	day := h.Day
+	for m := 0; m < h.Month-1 && m < 12; m++ {
+		day += int(MonthDays[m])
+	}
+	return day
+}
+
+//Reads a weather file, as EPW if its name ends in .epw and as TMY3 csv
+//otherwise.
+func ReadWeather(filename string) (*Weather, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1 //the header lines are shorter than the data
+	reader.LazyQuotes = true
+	var weather *Weather
+	if strings.HasSuffix(strings.ToLower(filename), ".epw") {
+		weather, err = readEPW(reader)
+	} else {
+		weather, err = readTMY3(reader)
+	}
+	if err != nil {
+		if perr, ok := err.(*ParseError); ok {
+			perr.File = filename
+			return nil, perr
+		}
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return weather, nil
+}
+
+//Reads an EPW file. The first line is the station's LOCATION and the
+//hourly data starts after the DATA PERIODS line.
+func readEPW(reader *csv.Reader) (*Weather, error) {
+	weather := &Weather{}
+	line := 0
+	for {
+		items, err := reader.Read()
+		line++
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 {
+			if len(items) < 9 || strings.TrimSpace(items[0]) != "LOCATION" {
+				return nil, errors.New("not an EPW file: the first line must be LOCATION")
+			}
+			weather.Station = strings.TrimSpace(items[1])
+			numbers := []*float64{&weather.Latitude, &weather.Longitude, &weather.TimeZone}
+			if err := parseNumbers(items, []int{6, 7, 8}, numbers); err != nil {
+				return nil, lineError(err, "", line)
+			}
+			continue
+		}
+		if line <= 8 || BlankLine(items) {
+			continue //the other header lines
+		}
+		if len(items) < 22 {
+			return nil, lineError(fmt.Errorf("expected at least 22 columns, found %d", len(items)), "", line)
+		}
+		var hour WeatherHour
+		var month, day, hourEnding float64
+		err = parseNumbers(items, []int{1, 2, 3, 6, 13, 14, 15, 21},
+			[]*float64{&month, &day, &hourEnding, &hour.Temp, &hour.GHI, &hour.DNI, &hour.DHI, &hour.Wind})
+		if err != nil {
+			return nil, lineError(err, "", line)
+		}
+		hour.Month, hour.Day, hour.Hour = int(month), int(day), int(hourEnding)
+		if err := addHour(weather, hour, items, []int{13, 14, 15}); err != nil {
+			return nil, lineError(err, "", line)
+		}
+	}
+	return weather, checkYear(weather)
+}
+
+//Reads a TMY3 csv file. The first line is the station (USAF number, name,
+//state, time zone, latitude, longitude, elevation), the second line names
+//the columns and every line after it is one hour.
+func readTMY3(reader *csv.Reader) (*Weather, error) {
+	weather := &Weather{}
+	items, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	if len(items) < 6 {
+		return nil, errors.New("not a TMY3 file: the first line must describe the station")
+	}
+	weather.Station = strings.TrimSpace(items[1])
+	err = parseNumbers(items, []int{3, 4, 5}, []*float64{&weather.TimeZone, &weather.Latitude, &weather.Longitude})
+	if err != nil {
+		return nil, lineError(err, "", 1)
+	}
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{"Date (MM/DD/YYYY)", "Time (HH:MM)", "GHI (W/m^2)", "DNI (W/m^2)", "DHI (W/m^2)", "Dry-bulb (C)", "Wspd (m/s)"}
+	columns := make([]int, len(names))
+	for i, name := range names {
+		columns[i] = -1
+		for col, item := range header {
+			if strings.TrimSpace(item) == name {
+				columns[i] = col
+			}
+		}
+		if columns[i] < 0 {
+			return nil, lineError(fmt.Errorf("missing column %q", name), "", 2)
+		}
+	}
+	for line := 3; ; line++ {
+		items, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if BlankLine(items) {
+			continue
+		}
+		if len(items) < len(header) {
+			return nil, lineError(fmt.Errorf("expected %d columns, found %d", len(header), len(items)), "", line)
+		}
+		var hour WeatherHour
+		err = parseNumbers(items, columns[2:], []*float64{&hour.GHI, &hour.DNI, &hour.DHI, &hour.Temp, &hour.Wind})
+		if err == nil {
+			hour.Month, hour.Day, hour.Hour, err = parseTMY3Time(items, columns[0], columns[1])
+		}
+		if err == nil {
+			err = addHour(weather, hour, items, columns[2:5])
+		}
+		if err != nil {
+			return nil, lineError(err, "", line)
+		}
+	}
+	return weather, checkYear(weather)
+}
+
+//Parses the date (MM/DD/YYYY) and time (HH:MM, 01:00 to 24:00) columns of a
+//TMY3 line.
+func parseTMY3Time(items []string, dateCol, timeCol int) (month, day, hour int, err error) {
+	date := strings.Split(strings.TrimSpace(items[dateCol]), "/")
+	clock := strings.Split(strings.TrimSpace(items[timeCol]), ":")
+	if len(date) != 3 || len(clock) != 2 {
+		return 0, 0, 0, &ParseError{Column: dateCol + 1, Value: items[dateCol] + " " + items[timeCol], Err: errors.New("not a date and time")}
+	}
+	month, err1 := strconv.Atoi(date[0])
+	day, err2 := strconv.Atoi(date[1])
+	hour, err3 := strconv.Atoi(clock[0])
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0, 0, 0, &ParseError{Column: dateCol + 1, Value: items[dateCol] + " " + items[timeCol], Err: errors.New("not a date and time")}
+	}
+	return month, day, hour, nil
+}
+
+//Parses the numbers in the columns cols into numbers.
+func parseNumbers(items []string, cols []int, numbers []*float64) error {
+	var err error
+	for i, col := range cols {
+		if col >= len(items) {
+			return fmt.Errorf("missing column %d", col+1)
+		}
+		*numbers[i], err = ParseColumn(items, col)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Checks an hour and adds it to the weather. The 29th of February of a leap
+//year is left out, so every year has the same hours. sunCols are the
+//columns of GHI, DNI and DHI, for the error message.
+func addHour(weather *Weather, hour WeatherHour, items []string, sunCols []int) error {
+	if hour.Month == 2 && hour.Day == 29 {
+		return nil
+	}
+	if hour.Month < 1 || hour.Month > 12 || hour.Day < 1 || hour.Day > int(MonthDays[hour.Month-1]) || hour.Hour < 1 || hour.Hour > 24 {
+		return fmt.Errorf("no such hour: month %d, day %d, hour %d", hour.Month, hour.Day, hour.Hour)
+	}
+	for i, value := range []float64{hour.GHI, hour.DNI, hour.DHI} {
+		if value < 0 || value > 2000 { //EPW marks missing sunlight as 9999
+			return &ParseError{Column: sunCols[i] + 1, Value: items[sunCols[i]], Err: errors.New("sunlight missing or out of range")}
+		}
+	}
+	weather.Hours = append(weather.Hours, hour)
+	return nil
+}
+
+//Checks that the weather file had a whole year of hours.
+func checkYear(weather *Weather) error {
+	if len(weather.Hours) != HoursPerYear {
+		return fmt.Errorf("expected %d hours, found %d", HoursPerYear, len(weather.Hours))
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package solar
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+//The hour whose values are checked, 4 July 13:00 (the hour ending at 13:00).
+var testHour = WeatherHour{Month: 7, Day: 4, Hour: 13, GHI: 850, DNI: 700, DHI: 120, Temp: 28.5, Wind: 3.2}
+
+//Gives the weather of an hour of the test files: testHour, or a cool night.
+func testWeather(month, day, hour int) WeatherHour {
+	if month == testHour.Month && day == testHour.Day && hour == testHour.Hour {
+		return testHour
+	}
+	return WeatherHour{Month: month, Day: day, Hour: hour, Temp: -1.5, Wind: 0.5}
+}
+
+//Calls add for every hour of a year, with 29 February when leap is set.
+func eachHour(leap bool, add func(month, day, hour int)) {
+	for m, days := range MonthDays {
+		if leap && m == 1 {
+			days++
+		}
+		for d := 1; d <= int(days); d++ {
+			for h := 1; h <= 24; h++ {
+				add(m+1, d, h)
+			}
+		}
+	}
+}
+
+//Makes a TMY3 csv file, with the columns where the NSRDB files have them
+//and the other columns filled with zeros. change can alter the lines
+//(0 is the station line).
+func tmy3File(leap bool, change func(lines []string) []string) string {
+	header := make([]string, 68)
+	for i := range header {
+		header[i] = fmt.Sprintf("Other %d", i+1)
+	}
+	header[0], header[1], header[4], header[7], header[10] = "Date (MM/DD/YYYY)", "Time (HH:MM)", "GHI (W/m^2)", "DNI (W/m^2)", "DHI (W/m^2)"
+	header[31], header[46] = "Dry-bulb (C)", "Wspd (m/s)"
+	lines := []string{`725090,"BOSTON LOGAN INT'L ARPT",MA,-5.0,42.367,-71.017,6`, strings.Join(header, ",")}
+	eachHour(leap, func(month, day, hour int) {
+		w := testWeather(month, day, hour)
+		items := make([]string, 68)
+		for i := range items {
+			items[i] = "0"
+		}
+		items[0], items[1] = fmt.Sprintf("%02d/%02d/1988", month, day), fmt.Sprintf("%02d:00", hour)
+		items[4], items[7], items[10] = fmt.Sprint(w.GHI), fmt.Sprint(w.DNI), fmt.Sprint(w.DHI)
+		items[31], items[46] = fmt.Sprint(w.Temp), fmt.Sprint(w.Wind)
+		lines = append(lines, strings.Join(items, ","))
+	})
+	if change != nil {
+		lines = change(lines)
+	}
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+//Makes an EPW file: the LOCATION line, 7 more header lines and the hours.
+//change can alter the lines (0 is the LOCATION line).
+func epwFile(leap bool, change func(lines []string) []string) string {
+	lines := []string{
+		"LOCATION,BOSTON LOGAN INTL ARPT,MA,USA,TMY3,725090,42.37,-71.02,-5.0,6.0",
+		"DESIGN CONDITIONS,0",
+		"TYPICAL/EXTREME PERIODS,0",
+		"GROUND TEMPERATURES,0",
+		"HOLIDAYS/DAYLIGHT SAVINGS,No,0,0,0",
+		"COMMENTS 1,Custom/User Format",
+		"COMMENTS 2,",
+		"DATA PERIODS,1,1,Data,Sunday, 1/ 1,12/31",
+	}
+	eachHour(leap, func(month, day, hour int) {
+		w := testWeather(month, day, hour)
+		//year,month,day,hour,minute,source,dry bulb,dew point,humidity,pressure,
+		//extraterrestrial horizontal and normal,infrared,GHI,DNI,DHI,4 illuminances,
+		//wind direction,wind speed, and 13 more
+		lines = append(lines, fmt.Sprintf("1988,%d,%d,%d,60,?9?9,%g,-8.0,60,101300,0,0,250,%g,%g,%g,0,0,0,0,270,%g,10,10,16.0,77777,9,999999999,0,0.1,0,88,0.2,0.0,0.0",
+			month, day, hour, w.Temp, w.GHI, w.DNI, w.DHI, w.Wind))
+	})
+	if change != nil {
+		lines = change(lines)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+//Checks the station and hours read from a test file.
+func checkWeather(t *testing.T, format string, weather *Weather, station string) {
+	t.Helper()
+	if weather.Station != station || weather.TimeZone != -5 {
+		t.Errorf("%s: got station %q in time zone %g", format, weather.Station, weather.TimeZone)
+	}
+	if len(weather.Hours) != HoursPerYear {
+		t.Fatalf("%s: got %d hours, want %d", format, len(weather.Hours), HoursPerYear)
+	}
+	first, last := weather.Hours[0], weather.Hours[HoursPerYear-1]
+	if first.Month != 1 || first.Day != 1 || first.Hour != 1 || last.Month != 12 || last.Day != 31 || last.Hour != 24 {
+		t.Errorf("%s: the year runs from %+v to %+v", format, first, last)
+	}
+	//The hour ending at 13:00 on day 185 is hour 12 of the day.
+	if got := weather.Hours[(testHour.DayOfYear()-1)*24+testHour.Hour-1]; got != testHour {
+		t.Errorf("%s: got %+v, want %+v", format, got, testHour)
+	}
+	if got := weather.Hours[59*24]; got.Month != 3 || got.Day != 1 || got.Temp != -1.5 || got.Wind != 0.5 {
+		t.Errorf("%s: the hour after 28 February is %+v", format, got)
+	}
+}
+
+func TestReadTMY3(t *testing.T) {
+	for _, leap := range []bool{false, true} {
+		weather, err := ReadWeather(writeFile(t, "725090TYA.CSV", tmy3File(leap, nil)))
+		if err != nil {
+			t.Fatalf("leap year %v: %v", leap, err)
+		}
+		checkWeather(t, "TMY3", weather, "BOSTON LOGAN INT'L ARPT")
+		if weather.Latitude != 42.367 || weather.Longitude != -71.017 {
+			t.Errorf("got %g, %g; want 42.367, -71.017", weather.Latitude, weather.Longitude)
+		}
+	}
+}
+
+func TestReadEPW(t *testing.T) {
+	for _, leap := range []bool{false, true} {
+		weather, err := ReadWeather(writeFile(t, "boston.EPW", epwFile(leap, nil)))
+		if err != nil {
+			t.Fatalf("leap year %v: %v", leap, err)
+		}
+		checkWeather(t, "EPW", weather, "BOSTON LOGAN INTL ARPT")
+		if weather.Latitude != 42.37 || weather.Longitude != -71.02 {
+			t.Errorf("got %g, %g; want 42.37, -71.02", weather.Latitude, weather.Longitude)
+		}
+	}
+}
+
+func TestReadWeatherErrors(t *testing.T) {
+	tests := []struct {
+		name, file, content, err string
+	}{
+		{"TMY3 hour missing", "a.csv", tmy3File(false, func(lines []string) []string { return lines[:len(lines)-1] }),
+			"expected 8760 hours, found 8759"},
+		{"TMY3 column missing", "a.csv", tmy3File(false, func(lines []string) []string {
+			lines[1] = strings.Replace(lines[1], "DNI (W/m^2)", "DNI", 1)
+			return lines
+		}), `a.csv:2: missing column "DNI (W/m^2)"`},
+		{"TMY3 sunlight missing", "a.csv", tmy3File(false, func(lines []string) []string {
+			lines[2] = strings.Replace(lines[2], ",0,0,0,", ",0,0,-9900,", 1) //the GHI column
+			return lines
+		}), "a.csv:3: column 5"},
+		{"TMY3 bad date", "a.csv", tmy3File(false, func(lines []string) []string {
+			lines[2] = strings.Replace(lines[2], "01/01/1988", "1988-01-01", 1)
+			return lines
+		}), "a.csv:3: column 1"},
+		{"EPW without LOCATION", "a.epw", epwFile(false, func(lines []string) []string { return lines[1:] }),
+			"the first line must be LOCATION"},
+		{"EPW sunlight missing", "a.epw", epwFile(false, func(lines []string) []string {
+			lines[8] = strings.Replace(lines[8], ",250,0,0,0,", ",250,0,9999,0,", 1) //the DNI column
+			return lines
+		}), "a.epw:9: column 15"},
+		{"EPW no such day", "a.epw", epwFile(false, func(lines []string) []string {
+			lines[8] = strings.Replace(lines[8], "1988,1,1,", "1988,13,1,", 1)
+			return lines
+		}), "no such hour"},
+	}
+	for _, test := range tests {
+		_, err := ReadWeather(writeFile(t, test.file, test.content))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got %v, want an error with %q", test.name, err, test.err)
+		}
+	}
+}
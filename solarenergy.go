@@ -4,48 +4,25 @@ Description: This program takes in roof, house size, and coordinates
 to give to the user some useful information about
 installing solar panels in their home.*/
 
-/*This file is written by Sarah Hsu, except functions MakeCity(),
-MakeCityMap(), and ReadFile() were written by Caryn Willis.*/
+/*This file is written by Sarah Hsu. The calculations it uses live in
+the solar package.*/
 
 package main
 
 import (
-	"bufio"
 	"fmt"
 	"html/template"
 	"log"
 	"math"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
-)
-
-/*This is a city struct which stores all of the data for each city.
-It stores the coordinates, temperature, solar radiation (at flat angle),
-optimal angle, optimal radiation (at optimal angle), average energy usage,
-installation cost, and a slice of 3 company names for each city.*/
-type City struct {
-	coordN    float64
-	coordW    float64
-	temp      float64
-	solarRad  float64
-	optAng    float64
-	optRad    float64
-	avgEnergy float64
-	instCost  float64
-	companies []string
-}
+	"time"
 
-/* This is a panel struct which stores the information for each type of solar
-panel. We have chosen 6 panels for the user to choose from here, each with
-information on its efficiency (percentage), watts, panel area, and price.*/
-type Panel struct {
-	efficiency float64
-	watts      float64
-	area       float64
-	price      float64
-}
+	"webtest/solar"
+)
 
 /*This is a coordinates struct which has a identifying name (for the web
 portion) , a value, and text (west or north) sections*/
@@ -66,36 +43,97 @@ type House struct {
 /*This is the struct storing all of the variables needed to be displayed
 on the web app.*/
 type PageVariables struct {
-	PageTitle       string        //Title of the page
-	PageCoordinates []Coordinates //Coordinates of the user
-	PageHouseSize   []House       //House size of the user
-	PageRoofSize    float64       //Roof size of the user
-	MyCity          string        //City name that is closest to the user
-	Output          float64       //Expected solar energy output
-	OptAngle        float64       //Optimal angle for panels
-	OptOutput       float64       //Optimal solar energy output
-	Usage           float64       //Average energy usage
-	Optimal         string        //Is it optimal to install solar power? Gives recommendation.
-	InstCost        float64       //Installation cost
-	Companies       []string      //3 company names
-	NumPanels       []int         //Number of panels needed for each brand
-	PanelCost       []int         //Cost of panels for each brand
-	Recommendation  []string      //Recommendation for each of the user preferences (efficiency, cost, production)
-	Percentage      int           //Percentage that their energy is covered by solar
-	Map             []string      //Map colors for each city (red, yellow, green)
-	RedList         []string      //List of cities in red
-	YellowList      []string      //List of cities in yellow
-	GreenList       []string      //List of cities in green
-	RedPercent      float64       //Percentage of cities in red
-	YellowPercent   float64       //Percentage of cities in yellow
-	GreenPercent    float64       //Percentage of cities in green
+	PageTitle       string                `json:"-"`                        //Title of the page
+	PageCoordinates []Coordinates         `json:"-"`                        //Coordinates of the user
+	PageHouseSize   []House               `json:"-"`                        //House size of the user
+	PageRoofSize    float64               `json:"-"`                        //Roof size of the user
+	MyCity          string                `json:"city,omitempty"`           //City name that is closest to the user
+	CityDistanceKm  float64               `json:"cityDistanceKm"`           //Distance to the closest city (km)
+	CityDistanceMi  float64               `json:"cityDistanceMiles"`        //Distance to the closest city (miles)
+	Mode            string                `json:"mode,omitempty"`           //How the city data was found: "nearest" or "interpolate"
+	Stations        []solar.StationWeight `json:"stations,omitempty"`       //Cities blended together and their weights (interpolate mode)
+	Roof            *solar.Orientation    `json:"roof,omitempty"`           //Pitch and direction of the roof, nil for a flat roof
+	Panel           string                `json:"panel,omitempty"`          //Panel the output, heat map and percentage are for
+	PanelNames      []string              `json:"-"`                        //Panels the user can choose from, in the order of solar.csv
+	Output          float64               `json:"output"`                   //Expected solar energy output
+	Temp            float64               `json:"temp"`                     //Average temperature of the city (Fahrenheit)
+	TempLoss        float64               `json:"tempLoss"`                 //Output lost to the temperature (percentage, negative when the cold adds output)
+	Losses          []solar.LossItem      `json:"losses,omitempty"`         //System losses that went into the output, itemized
+	TotalLoss       float64               `json:"totalLoss"`                //All of the system losses together (percentage)
+	OptAngle        float64               `json:"optAngle"`                 //Optimal angle for panels
+	OptOutput       float64               `json:"optOutput"`                //Optimal solar energy output
+	Usage           float64               `json:"usage"`                    //Average energy usage
+	Optimal         string                `json:"optimal,omitempty"`        //Is it optimal to install solar power? Gives recommendation.
+	InstCost        float64               `json:"instCost"`                 //Installation cost
+	Companies       []string              `json:"companies,omitempty"`      //3 company names
+	PanelOptions    []solar.PanelOption   `json:"panelOptions,omitempty"`   //Output, number of panels needed and cost for each brand
+	Recommendation  []string              `json:"recommendation,omitempty"` //Recommendation for each of the user preferences (efficiency, cost, production)
+	Percentage      int                   `json:"percentage"`               //Percentage that their energy is covered by solar
+	Monthly         []MonthRow            `json:"monthly,omitempty"`        //Solar energy output and energy usage in each month
+	Forecast        []solar.YearOutput    `json:"forecast,omitempty"`       //Solar energy output of the chosen panels that would be bought, in each year of their life
+	Lifetime        float64               `json:"lifetime"`                 //Solar energy output over all of the years of the forecast (kwh)
+	Finance         *solar.Finance        `json:"finance,omitempty"`        //Payback, NPV, IRR and LCOE of the chosen panel
+	Price           float64               `json:"price"`                    //What a kwh of the chosen panel's output saves on the bill in the first year (dollars per kwh)
+	Bills           *solar.BillComparison `json:"bills,omitempty"`          //Yearly electricity bill without and with the chosen panel
+	Batteries       []solar.BatteryOption `json:"batteries,omitempty"`      //Every battery of batteries.csv added to the chosen panel
+	PanelsOnly      *solar.Dispatch       `json:"panelsOnly,omitempty"`     //Energy taken from and sent to the grid with the chosen panel and no battery
+	Measured        *solar.Usage          `json:"measured,omitempty"`       //Usage read from the home's meter or bills (without the hourly values), nil when the city average is used
+	CityUsage       float64               `json:"cityUsage"`                //Average energy usage in the area for the house size, when the home's own usage replaces it (kwh per month, 0 otherwise)
+	UsageVsCity     float64               `json:"usageVsCity"`              //How much more energy the home uses than that average (percentage, negative when it uses less, 0 without the home's usage)
+	Tariffs         []solar.Tariff        `json:"-"`                        //Tariffs the user can choose from
+	Map             []string              `json:"map,omitempty"`            //Map colors for each city (red, yellow, green)
+	MapCities       []string              `json:"mapCities,omitempty"`      //City names in the same order as Map
+	RedList         []string              `json:"redList,omitempty"`        //List of cities in red
+	YellowList      []string              `json:"yellowList,omitempty"`     //List of cities in yellow
+	GreenList       []string              `json:"greenList,omitempty"`      //List of cities in green
+	RedPercent      float64               `json:"redPercent"`               //Percentage of cities in red
+	YellowPercent   float64               `json:"yellowPercent"`            //Percentage of cities in yellow
+	GreenPercent    float64               `json:"greenPercent"`             //Percentage of cities in green
+	FormValues      map[string]string     `json:"-"`                        //Values the user entered, shown again when the form has errors
+	Errors          FormErrors            `json:"-"`                        //Error message for every invalid input
+}
+
+//This is one month of the seasonal chart on the results page.
+type MonthRow struct {
+	Month     string  `json:"month"`     //Short month name
+	Output    float64 `json:"output"`    //Expected solar energy output (kwh)
+	Usage     float64 `json:"usage"`     //Energy usage (kwh)
+	CityUsage float64 `json:"cityUsage"` //Average energy usage in the area for the house size, when the home's own usage is known (kwh, 0 otherwise)
+	Shortfall float64 `json:"shortfall"` //Usage the panels don't cover (kwh)
+	OutputBar float64 `json:"-"`         //Length of the output bar (percentage of the longest bar)
+	UsageBar  float64 `json:"-"`         //Length of the usage bar (percentage of the longest bar)
+	CityBar   float64 `json:"-"`         //Length of the area's usage bar (percentage of the longest bar)
+}
+
+//This is the web server. It keeps the data files in memory, so the handlers
+//don't read them again for every request.
+type Server struct {
+	Data          *solar.Store //City and panel data
+	AdminToken    string       //Token needed to call /admin/reload (empty turns it off)
+	MaxDistanceKm float64      //Locations farther than this from every city are rejected (0 means no limit)
 }
 
 func main() {
-	http.HandleFunc("/", DisplayCoordinates)          //DisplayCoordinates() loads when called with / at the end of the URL
-	http.HandleFunc("/selected", UserSelected)        //UserSelected() will load after the form with / is submitted
-	http.HandleFunc("/heatmap", DisplayHouseSize)     //DisplayHouseSize() will load when URL is called with /heatmap, or click tab
-	http.HandleFunc("/displayheatmap", UserInteracts) //UserInteracts() will load after form with /heatmap is submitted
+	//Load and check the data files before serving, so bad data stops the
+	//server here instead of in the middle of a request.
+	store, err := solar.NewStore(solar.DataFiles{Cities: "energy.csv", Panels: "solar.csv", Monthly: "monthly.csv", Batteries: "batteries.csv", Incentives: "incentives.json", Tariffs: "tariffs"})
+	if err != nil {
+		log.Fatal("loading data: ", err)
+	}
+	server := &Server{Data: store, AdminToken: os.Getenv("ADMIN_TOKEN"), MaxDistanceKm: getMaxDistance()}
+	if interval := getReloadInterval(); interval > 0 {
+		go store.Watch(interval, nil, LogReload) //reload the data files when they change
+	}
+
+	http.HandleFunc("/", server.DisplayCoordinates)          //DisplayCoordinates() loads when called with / at the end of the URL
+	http.HandleFunc("/selected", server.UserSelected)        //UserSelected() will load after the form with / is submitted
+	http.HandleFunc("/heatmap", server.DisplayHouseSize)     //DisplayHouseSize() will load when URL is called with /heatmap, or click tab
+	http.HandleFunc("/displayheatmap", server.UserInteracts) //UserInteracts() will load after form with /heatmap is submitted
+	http.HandleFunc("/api/v1/estimate", server.EstimateAPI)  //JSON version of /selected
+	http.HandleFunc("/api/v1/heatmap", server.HeatMapAPI)    //JSON version of /displayheatmap
+	http.HandleFunc("/api/v1/simulate", server.SimulateAPI)  //Hourly simulation with a city's weather file
+	http.HandleFunc("/api/v1/usage", server.UsageAPI)        //Reads a Green Button file
+	http.HandleFunc("/admin/reload", server.ReloadAPI)       //Reloads the data files
 	log.Fatal(http.ListenAndServe(getPort(), nil))
 }
 
@@ -108,11 +146,46 @@ func getPort() string {
 	return ":8080"
 }
 
+/*This function gives how often the server checks the data files for changes.
+Set RELOAD_INTERVAL to a duration such as "1m", or to "0" to turn it off.*/
+func getReloadInterval() time.Duration {
+	p := os.Getenv("RELOAD_INTERVAL")
+	if p == "" {
+		return 30 * time.Second
+	}
+	interval, err := time.ParseDuration(p)
+	if err != nil {
+		log.Fatal("RELOAD_INTERVAL: ", err)
+	}
+	return interval
+}
+
+/*This function gives how far (km) a location can be from the closest city
+before the server refuses to estimate it. Set MAX_DISTANCE_KM to change it,
+or to "0" for no limit.*/
+func getMaxDistance() float64 {
+	p := os.Getenv("MAX_DISTANCE_KM")
+	if p == "" {
+		return 500
+	}
+	maxKm, err := strconv.ParseFloat(p, 64)
+	if err != nil || maxKm < 0 {
+		log.Fatal("MAX_DISTANCE_KM must be a number of km, not ", p)
+	}
+	return maxKm
+}
+
 //This is the function where it initially displays the page asking for the
 //user's info such as house size and coordinates and then loads
 //to the next page after this information is submitted.
-func DisplayCoordinates(w http.ResponseWriter, r *http.Request) {
+func (s *Server) DisplayCoordinates(w http.ResponseWriter, r *http.Request) {
+	RenderPage(w, "solarenergy.html", http.StatusOK, EstimateForm(s.Data.Dataset(), nil, nil))
+}
 
+//Makes the page variables of the form asking for the coordinates, house
+//and roof size and panel. values holds what the user entered before, keyed
+//by input name, and errs holds the error message of every invalid input.
+func EstimateForm(data *solar.Dataset, values map[string]string, errs FormErrors) PageVariables {
 	Title := "Solar Energy"
 	MyCoordinates := []Coordinates{
 		Coordinates{"coordinaten", 0, "North"},
@@ -124,368 +197,224 @@ func DisplayCoordinates(w http.ResponseWriter, r *http.Request) {
 
 	var MyRoof float64
 
-	MyPageVariables := PageVariables{
+	return PageVariables{
 		PageTitle:       Title,
 		PageCoordinates: MyCoordinates,
 		PageHouseSize:   MyHouse,
 		PageRoofSize:    MyRoof,
+		PanelNames:      data.PanelNames,
+		Tariffs:         data.TariffList(),
+		FormValues:      values,
+		Errors:          errs,
 	}
+}
 
-	t, err := template.ParseFiles("solarenergy.html") //parse the html file solarenergy.html
-	if err != nil {
-		log.Print("template parsing error: ", err)
+//This is the main function where the user interacts with the web app.
+//There are several different variables in use here to be able to interact
+//with. If any input is invalid, the form is shown again with the messages.
+func (s *Server) UserSelected(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxUpload)
+	input, errs := ParseEstimateForm(r)
+	data := s.Data.Dataset()
+	var MyPageVariables PageVariables
+	if len(errs) == 0 {
+		MyPageVariables, errs = s.MakeEstimate(data, input)
 	}
-
-	err = t.Execute(w, MyPageVariables) //execute the template and pass it the MyPageVariables
-	if err != nil {
-		log.Print("template executing error: ", err)
+	if len(errs) > 0 {
+		values := map[string]string{
+			"coordinaten":  r.Form.Get("coordinaten"),
+			"coordinatew":  r.Form.Get("coordinatew"),
+			"housesize":    r.Form.Get("housesize"),
+			"roofsize":     r.Form.Get("roofsize"),
+			"mode":         r.Form.Get("mode"),
+			"neighbors":    r.Form.Get("neighbors"),
+			"tilt":         r.Form.Get("tilt"),
+			"azimuth":      r.Form.Get("azimuth"),
+			"panel":        r.Form.Get("panel"),
+			"years":        r.Form.Get("years"),
+			"price":        r.Form.Get("price"),
+			"tariff":       r.Form.Get("tariff"),
+			"loanrate":     r.Form.Get("loanrate"),
+			"loanyears":    r.Form.Get("loanyears"),
+			"leasepayment": r.Form.Get("leasepayment"),
+			"ppaprice":     r.Form.Get("ppaprice"),
+		}
+		for _, item := range solar.DefaultLosses().Items() {
+			values[strings.ToLower(item.Key)] = r.Form.Get(strings.ToLower(item.Key))
+		}
+		RenderPage(w, "solarenergy.html", http.StatusBadRequest, EstimateForm(data, values, errs))
+		return
 	}
 
+	MyPageVariables.PageTitle = "Your Home"
+	RenderPage(w, "solarenergy.html", http.StatusOK, MyPageVariables)
 }
 
-//This is the main function where the user interacts with the web app.
-//There are several different variables in use here to be able to interact
-//with.
-func UserSelected(w http.ResponseWriter, r *http.Request) {
-	r.ParseForm() //Parse the page for the variables needed
-	cityData := MakeCityMap("energy.csv")
-	solarPanels := MakeSolarMap("solar.csv")
-	northcoord, err1 := strconv.ParseFloat(r.Form.Get("coordinaten"), 64)
-	ErrorMessage(err1, "north coordinate", northcoord)
-	westcoord, err2 := strconv.ParseFloat(r.Form.Get("coordinatew"), 64)
-	ErrorMessage(err2, "west coordinate", westcoord)
-	houseSize, err3 := strconv.ParseFloat(r.Form.Get("housesize"), 64)
-	ErrorMessage(err3, "house size", houseSize)
-	roofSize, err4 := strconv.ParseFloat(r.Form.Get("roofsize"), 64)
-	ErrorMessage(err4, "roof size", roofSize)
-	closestcity := ClosestCity(cityData, northcoord, westcoord)
-	solarOutput := SolarOutput(closestcity, cityData, "horizontal", 15, roofSize)
-	solarOutput = float64(int(solarOutput*100)) / 100
-	optAngle := OptAngle(cityData, closestcity)
-	optEnergy := OptEnergy(cityData, closestcity, 15, roofSize)
-	optEnergy = float64(int(optEnergy*100)) / 100
-	avgUsage := AverageEnergy(cityData, closestcity) * houseSize
-	avgUsage = float64(int(avgUsage*100)) / 100
-	percent, recommendation := IsItOptimal(avgUsage, solarOutput)
-	percentage := int(percent * 100)
-	companylist := Companies(closestcity, cityData)
-	instCost := InstallationCost(cityData, closestcity)
-	instCost = float64(int(instCost*100)) / 100
-	numPanels, panelCost := CalcCostBrand(solarOutput, roofSize, cityData, closestcity, solarPanels)
-	preferences := Preferences(panelCost, solarPanels, closestcity, cityData, houseSize)
-
-	Title := "Your Home"
-	MyPageVariables := PageVariables{
-		PageTitle:      Title,
-		MyCity:         closestcity,
-		Output:         solarOutput,
-		OptAngle:       optAngle,
-		OptOutput:      optEnergy,
-		Usage:          avgUsage,
-		Optimal:        recommendation,
-		InstCost:       instCost,
-		Companies:      companylist,
-		NumPanels:      numPanels,
-		PanelCost:      panelCost,
-		Recommendation: preferences,
-		Percentage:     percentage,
-	}
+//These are the functions the html files can call besides the built in ones.
+var TemplateFuncs = template.FuncMap{
+	"percent": func(fraction float64) float64 { return fraction * 100 }, //0.25 becomes 25
+	"neg":     func(number float64) float64 { return -number },
+}
 
-	t, err := template.ParseFiles("solarenergy.html") //parse the html file solarenergy.html
+//Parses the html file and executes it with the page variables.
+func RenderPage(w http.ResponseWriter, filename string, status int, PageVars PageVariables) {
+	t, err := template.New(filepath.Base(filename)).Funcs(TemplateFuncs).ParseFiles(filename) //parse the html file
 	if err != nil {
 		log.Print("template parsing error: ", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
 	}
 
-	err = t.Execute(w, MyPageVariables) //execute the template and pass it the MyPageVariables
+	w.WriteHeader(status)
+	err = t.Execute(w, PageVars) //execute the template and pass it the PageVars
 	if err != nil {
 		log.Print("template executing error: ", err)
 	}
 }
 
-//Read in the file.
-func ReadFile(filename string) []string {
-	//reads file and makes a line for each file
-	file, err := os.Open(filename)
+//Runs the solar calculations for one home and stores the results in the
+//page variables. Both the web page and the JSON API use it. If the location
+//is too far from every city, the problem is returned as a form error.
+func (s *Server) MakeEstimate(data *solar.Dataset, input EstimateInput) (PageVariables, FormErrors) {
+	houseSize, roofSize := input.HouseSize, input.RoofSize
+	cityData := data.Cities
+	errs := make(FormErrors)
+	panel := errs.Panel(data, input.Panel)
+	nearest, err := solar.NearestCity(data.Index, input.Latitude, input.Longitude, s.MaxDistanceKm)
 	if err != nil {
-		fmt.Println("Error: couldn't open the file")
-		os.Exit(1)
-	}
-	lines := make([]string, 0)
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-	if scanner.Err() != nil {
-		fmt.Println("Error: there was some kind of error during the file reading")
-		os.Exit(1)
+		errs["location"] = fmt.Sprintf("We don't have solar data for this location: %v.", err)
 	}
-	file.Close()
-	return lines
-}
-
-//Makes a map data structure of all of the City objects.
-func MakeCityMap(filename string) map[string]City {
-	lines := ReadFile(filename)
-	cityData := make(map[string]City)
-	for i := 0; i < len(lines); i++ {
-		var items []string = strings.Split(lines[i], ",")
-		cityName := items[0]
-		cityData[cityName] = MakeCity(cityData, items)
+	tariff, ok := data.Tariff(input.Tariff, nearest.City)
+	if !ok {
+		errs["tariff"] = fmt.Sprintf("We don't have a tariff named %q.", input.Tariff)
 	}
-	delete(cityData, "")
-	return cityData
-}
-
-//Creates a City object with its characteristics using City struct.
-func MakeCity(cityData map[string]City, items []string) City {
-	var city City
-	city.coordN, _ = strconv.ParseFloat(items[1], 64)
-	city.coordW, _ = strconv.ParseFloat(items[2], 64)
-	city.temp, _ = strconv.ParseFloat(items[3], 64)
-	city.solarRad, _ = strconv.ParseFloat(items[4], 64)
-	city.optAng, _ = strconv.ParseFloat(items[5], 64)
-	city.optRad, _ = strconv.ParseFloat(items[6], 64)
-	city.avgEnergy, _ = strconv.ParseFloat(items[7], 64)
-	city.instCost, _ = strconv.ParseFloat(items[8], 64)
-	var companyNames []string = strings.Split(items[9], ";")
-	for i := range companyNames {
-		city.companies = append(city.companies, companyNames[i])
+	if input.Finance.Price > 0 {
+		tariff = solar.FlatTariff(input.Finance.Price) //the price the user pays replaces the utility's rates
 	}
-	return city
-}
-
-//Make the map data structure of all of the different solar panel brands.
-func MakeSolarMap(filename string) map[string]Panel {
-	lines := ReadFile(filename)
-	solarPanels := make(map[string]Panel)
-	for i := 0; i < len(lines); i++ {
-		var items []string = strings.Split(lines[i], ",")
-		panelName := items[0]
-		solarPanels[panelName] = MakePanel(solarPanels, items)
+	if len(errs) > 0 {
+		return PageVariables{}, errs
 	}
-	keys := make([]string, 0)
-	for key, _ := range solarPanels {
-		keys = append(keys, key)
+	closestcity := nearest.City
+	city := cityData[closestcity]
+	var stations []solar.StationWeight
+	if input.Mode == ModeInterpolate {
+		city, stations = solar.Interpolate(data.Index, input.Latitude, input.Longitude, input.Neighbors)
 	}
-	return solarPanels
-}
-
-//Make a Solar Panel object using Panel struct.
-func MakePanel(solarPanels map[string]Panel, items []string) Panel {
-	var panel Panel
-	panel.efficiency, _ = strconv.ParseFloat(items[1], 64)
-	panel.watts, _ = strconv.ParseFloat(items[2], 64)
-	panel.area, _ = strconv.ParseFloat(items[3], 64)
-	panel.price, _ = strconv.ParseFloat(items[4], 64)
-	return panel
-}
-
-//Finds the city closest to their coordinates.
-func ClosestCity(cityData map[string]City, userCoordN, userCoordW float64) string {
-	distance := 10000.00
-	closestCityName := ""
-	var cityDistance float64
-	if userCoordN < 0 {
-		userCoordN *= -1
-	}
-	if userCoordW < 0 {
-		userCoordW *= -1
-	}
-	for city, data := range cityData {
-		cityDistance = math.Sqrt(math.Pow((userCoordN-data.coordN), 2) + math.Pow((userCoordW-data.coordW), 2))
-		if cityDistance < distance {
-			distance = cityDistance
-			closestCityName = city
+	tempFactor := solar.TemperatureFactor(city, panel, solar.RoofRadiation(city, input.Roof))
+	solarOutput := solar.RoofOutput(city, input.Roof, panel.Efficiency, roofSize, input.Losses) * tempFactor
+	solarOutput = float64(int(solarOutput*100)) / 100
+	optEnergy := solar.OptEnergy(city, panel.Efficiency, roofSize, input.Losses) * solar.TemperatureFactor(city, panel, city.OptRad)
+	optEnergy = float64(int(optEnergy*100)) / 100
+	avgUsage := solar.AverageEnergy(city) * houseSize
+	avgUsage = float64(int(avgUsage*100)) / 100
+	load := solar.LoadProfile(avgUsage*12, houseSize, city)
+	//the home's own readings or bills replace the average usage of the city
+	var measured *solar.Usage
+	var cityUsage, usageVsCity float64
+	var cityMonthly [12]float64
+	if input.Usage != nil {
+		cityUsage, cityMonthly = avgUsage, solar.MonthlyTotals(load)
+		load = input.Usage.Profile(load)
+		avgUsage = 0
+		for _, kwh := range load {
+			avgUsage += kwh / 12
+		}
+		avgUsage = float64(int(avgUsage*100)) / 100
+		if cityUsage > 0 {
+			usageVsCity = float64(int((avgUsage/cityUsage-1)*1000)) / 10
 		}
+		summary := *input.Usage
+		summary.Hourly = nil
+		measured = &summary
 	}
-	return closestCityName
-}
-
-//Calculates expected generated energy from solar panels. (in kwh per month)
-func SolarOutput(cityName string, cityData map[string]City, angleType string, efficiency, houseSize float64) float64 {
-	var radiation float64
-	//241.5479 meters squared as solar panel area (average house size)
-	//assume standard 15% efficiency
-	//0.75 default performance ratio
-	// E = Solar Panel Area * solar panel efficiency * radiation * performance ratio
-	if angleType == "horizontal" {
-		radiation = cityData[cityName].solarRad
-	} else if angleType == "optimal" {
-		radiation = cityData[cityName].optRad
+	percent, recommendation := solar.IsItOptimal(avgUsage, solarOutput)
+	percentage := int(percent * 100)
+	instCost := solar.InstallationCost(city)
+	instCost = float64(int(instCost*100)) / 100
+	panelOptions := solar.CalcCostBrand(avgUsage, roofSize, city, input.Roof, input.Losses, data.PanelList())
+	solar.ApplyIncentives(panelOptions, city, data.Incentives, input.InstallDate)
+	bills := solar.BillModel{Tariff: tariff, Load: load, Shape: solar.OutputShape(city, input.Roof)}
+	solar.AnalyzeOptions(panelOptions, data.PanelList(), input.Losses, input.Years, input.Finance, bills)
+	preferences := solar.Preferences(panelOptions)
+	var finance *solar.Finance
+	var installed float64 //output of the chosen panels that would be bought
+	for _, option := range panelOptions {
+		if option.Name == panel.Name {
+			finance, installed = option.Finance, option.Installed
+		}
 	}
-	houseSize *= 0.092903 //convert square feet to square meters
-	energyOutput := houseSize * efficiency * radiation * 0.75
-	return energyOutput / 12
-}
-
-//Gives the potential optimal energy output for their home.
-func OptEnergy(cityData map[string]City, cityName string, efficiency, houseSize float64) float64 {
-	optOutput := SolarOutput(cityName, cityData, "optimal", efficiency, houseSize)
-	return optOutput
-}
-
-//Output the optimal angle that they should use to get the optimal output.
-func OptAngle(cityData map[string]City, cityName string) float64 {
-	data := cityData[cityName]
-	optAngle := data.optAng
-	return optAngle
-}
-
-//Calculates average energy required at a house in their area. (kwh per month)
-func AverageEnergy(cityData map[string]City, cityName string) float64 {
-	data := cityData[cityName]
-	averageEnergy := data.avgEnergy
-	return averageEnergy / 2600
-}
-
-//Gives a recommendation based on energy produced from solar panels and energy requirement.
-func IsItOptimal(avgUsage float64, solarOutput float64) (float64, string) {
-	percentage := solarOutput / avgUsage
-	if percentage <= 0.60 {
-		return percentage, "is not recommended"
-	} else if percentage > .60 && percentage < .8 {
-		return percentage, "is recommended"
-	} else {
-		return percentage, "is highly recommended"
+	//the forecast starts from new panels, with the panel's own LID in place of the loss stack's
+	forecast := solar.Forecast(installed*12/(1-input.Losses.LID/100), panel, input.Years)
+	comparison := bills.Compare(forecast[0].Output)
+	batteries, panelsOnly := solar.CompareBatteries(bills, forecast[0].Output, data.Batteries, input.Finance.Escalation)
+	var roof *solar.Orientation
+	if !input.Roof.Flat() {
+		roof = &input.Roof
 	}
-}
-
-//Calculates the installation cost.
-func InstallationCost(cityData map[string]City, cityName string) float64 {
-	return cityData[cityName].instCost * 5000
-}
 
-//Calculates the number of solar panels needed on their house.
-func NumSolarPanels(energyOutput, houseSize float64, cityData map[string]City, panelName, cityName string, solarPanels map[string]Panel) int {
-	houseSize *= 0.092903 //convert square feet to square meters
-	oneSolarPanelOutput := (energyOutput * 12 / houseSize) * solarPanels[panelName].area
-	numPanels := cityData[cityName].avgEnergy / oneSolarPanelOutput
-	return int(numPanels)
-}
-
-//Calculates how much it would cost for user to get that brand of solar panels on their house.
-func SolarPanelCost(energyOutput, houseSize float64, cityData map[string]City, panelName, cityName string, solarPanels map[string]Panel, numPanels int) float64 {
-	houseSize *= 0.092903 //convert square feet to square meters
-	cost := solarPanels[panelName].price * float64(numPanels)
-	return cost + InstallationCost(cityData, cityName)
-}
-
-//Puts companies close to their city into a slice of strings.
-func Companies(cityName string, cityData map[string]City) []string {
-	data := cityData[cityName]
-	companyNames := data.companies
-	return companyNames
-}
-
-//Calculates the cost and number of panels required for each brand of solar panel.
-//0: Suntech, 1: Samsung, 2: Kyocera, 3: Canadian Solar, 4: Grape Solar 390W, 5: Grape Solar 250
-func CalcCostBrand(energyOutput, houseSize float64, cityData map[string]City, cityName string, solarPanels map[string]Panel) ([]int, []int) {
-	NumPanels := make([]int, 6)
-	PanelCosts := make([]int, 6)
-	NumPanels[0] = NumSolarPanels(energyOutput, houseSize, cityData, "Suntech", cityName, solarPanels)
-	NumPanels[1] = NumSolarPanels(energyOutput, houseSize, cityData, "Samsung", cityName, solarPanels)
-	NumPanels[2] = NumSolarPanels(energyOutput, houseSize, cityData, "Kyocera", cityName, solarPanels)
-	NumPanels[3] = NumSolarPanels(energyOutput, houseSize, cityData, "CanadianSolar", cityName, solarPanels)
-	NumPanels[4] = NumSolarPanels(energyOutput, houseSize, cityData, "GrapeSolar390W", cityName, solarPanels)
-	NumPanels[5] = NumSolarPanels(energyOutput, houseSize, cityData, "GrapeSolar250", cityName, solarPanels)
-	PanelCosts[0] = int(SolarPanelCost(energyOutput, houseSize, cityData, "Suntech", cityName, solarPanels, NumPanels[0]))
-	PanelCosts[1] = int(SolarPanelCost(energyOutput, houseSize, cityData, "Samsung", cityName, solarPanels, NumPanels[1]))
-	PanelCosts[2] = int(SolarPanelCost(energyOutput, houseSize, cityData, "Kyocera", cityName, solarPanels, NumPanels[2]))
-	PanelCosts[3] = int(SolarPanelCost(energyOutput, houseSize, cityData, "CanadianSolar", cityName, solarPanels, NumPanels[3]))
-	PanelCosts[4] = int(SolarPanelCost(energyOutput, houseSize, cityData, "GrapeSolar390W", cityName, solarPanels, NumPanels[4]))
-	PanelCosts[5] = int(SolarPanelCost(energyOutput, houseSize, cityData, "GrapeSolar250", cityName, solarPanels, NumPanels[5]))
-	return NumPanels, PanelCosts
-}
-
-//Preferences in a slice, with 0: min cost, 1: max output, 2: max efficiency
-func Preferences(panelCost []int, solarPanels map[string]Panel, cityName string, cityData map[string]City, houseSize float64) []string {
-	minCostPanel := FindMinCostPanel(panelCost)
-	efficiencyarray := MakeEfficiencyArray(solarPanels)
-	mostEfficientPanel := FindMostEfficient(efficiencyarray)
-	maxOutput := FindMaxOutput(efficiencyarray, cityName, cityData, houseSize)
-	preferences := []string{minCostPanel, maxOutput, mostEfficientPanel}
-	return preferences
-}
-
-//Converts index to panel brand name.
-func IdxToPanel(idx int) string {
-	switch idx {
-	case 0:
-		return "Suntech"
-	case 1:
-		return "Samsung"
-	case 2:
-		return "Kyocera"
-	case 3:
-		return "CanadianSolar"
-	case 4:
-		return "GrapeSolar390W"
-	case 5:
-		return "GrapeSolar250"
-	}
-	return ""
-}
-
-//Puts panel brand efficiencies in an array according to the same indices as above.
-func MakeEfficiencyArray(solarPanels map[string]Panel) []float64 {
-	efficiencyArray := make([]float64, 6)
-	efficiencyArray[0] = solarPanels["Suntech"].efficiency
-	efficiencyArray[1] = solarPanels["Samsung"].efficiency
-	efficiencyArray[2] = solarPanels["Kyocera"].efficiency
-	efficiencyArray[3] = solarPanels["CanadianSolar"].efficiency
-	efficiencyArray[4] = solarPanels["GrapeSolar390W"].efficiency
-	efficiencyArray[5] = solarPanels["GrapeSolar250"].efficiency
-	return efficiencyArray
-}
-
-//Gives the minimum cost panel option.
-func FindMinCostPanel(panelCost []int) string {
-	minCost := panelCost[0]
-	minCostIDX := 0
-	for idx, cost := range panelCost {
-		if cost < minCost {
-			minCost = cost
-			minCostIDX = idx
-		}
+	return PageVariables{
+		MyCity:         closestcity,
+		Panel:          panel.Name,
+		CityDistanceKm: float64(int(nearest.DistanceKm*10)) / 10,
+		CityDistanceMi: float64(int(nearest.DistanceMiles*10)) / 10,
+		Output:         solarOutput,
+		Temp:           city.Temp,
+		TempLoss:       solar.TempLoss(tempFactor),
+		Losses:         input.Losses.Items(),
+		TotalLoss:      input.Losses.Total(),
+		OptAngle:       float64(int(city.OptAng*10)) / 10,
+		Mode:           input.Mode,
+		Stations:       stations,
+		Roof:           roof,
+		OptOutput:      optEnergy,
+		Usage:          avgUsage,
+		Optimal:        recommendation,
+		InstCost:       instCost,
+		Companies:      city.Companies,
+		PanelOptions:   panelOptions,
+		Recommendation: preferences,
+		Percentage:     percentage,
+		Forecast:       forecast,
+		Finance:        finance,
+		Price:          float64(int(bills.Value(forecast[0].Output)*1000)) / 1000,
+		Bills:          &comparison,
+		Batteries:      batteries,
+		PanelsOnly:     &panelsOnly,
+		Measured:       measured,
+		CityUsage:      cityUsage,
+		UsageVsCity:    usageVsCity,
+		Lifetime:       solar.LifetimeOutput(forecast),
+		Monthly:        MakeMonthly(city, input.Roof, panel, input.Losses, tempFactor, roofSize, solar.MonthlyTotals(load), cityMonthly),
+	}, nil
+}
+
+//Makes the month by month rows of the seasonal chart. The output is derated
+//by tempFactor, usage is the energy used in each month, and cityUsage the
+//average usage in the area when the home's own usage is known (zeros
+//otherwise).
+func MakeMonthly(city solar.City, roof solar.Orientation, panel solar.Panel, losses solar.Losses, tempFactor, roofSize float64, usage, cityUsage [12]float64) []MonthRow {
+	output := solar.MonthlyRoofOutput(city, roof, panel.Efficiency, roofSize, losses)
+	for m := range output {
+		output[m] *= tempFactor
 	}
-	minCostPanel := IdxToPanel(minCostIDX)
-	return minCostPanel
-}
-
-//Finds the brand of solar panel with the highest efficiency.
-func FindMostEfficient(efficiencyarray []float64) string {
-	mostEfficient := efficiencyarray[0]
-	mostEfficientIDX := 0
-	for idx, efficiency := range efficiencyarray {
-		if efficiency > mostEfficient {
-			mostEfficient = efficiency
-			mostEfficientIDX = idx
+	rows := make([]MonthRow, 12)
+	var longest float64
+	for m := range rows {
+		rows[m] = MonthRow{
+			Month:     solar.MonthNames[m],
+			Output:    float64(int(output[m]*100)) / 100,
+			Usage:     float64(int(usage[m]*100)) / 100,
+			CityUsage: float64(int(cityUsage[m]*100)) / 100,
+			Shortfall: float64(int(math.Max(0, usage[m]-output[m])*100)) / 100,
 		}
+		longest = math.Max(longest, math.Max(rows[m].Output, math.Max(rows[m].Usage, rows[m].CityUsage)))
 	}
-	mostEfficientPanel := IdxToPanel(mostEfficientIDX)
-	return mostEfficientPanel
-}
-
-//Finds the panel brand with the highest output of solar energy.
-func FindMaxOutput(efficiencyarray []float64, cityName string, cityData map[string]City, houseSize float64) string {
-	var maxOutput, output float64
-	var maxIDX int
-	for i := range efficiencyarray {
-		output = SolarOutput(cityName, cityData, "horizontal", efficiencyarray[i], houseSize)
-		if output > maxOutput {
-			maxOutput = output
-			maxIDX = i
+	if longest > 0 {
+		for m := range rows {
+			rows[m].OutputBar = float64(int(rows[m].Output/longest*1000)) / 10
+			rows[m].UsageBar = float64(int(rows[m].Usage/longest*1000)) / 10
+			rows[m].CityBar = float64(int(rows[m].CityUsage/longest*1000)) / 10
 		}
 	}
-	maxOutputPanel := IdxToPanel(maxIDX)
-	return maxOutputPanel
-}
-
-//Error message, error if not nil or less than 0.
-func ErrorMessage(err error, input string, variableInput float64) {
-	if err != nil { //there was a problem
-		fmt.Printf("Error: Number entered for %s was invalid.\n", input)
-	} else if variableInput <= 0 {
-		fmt.Printf("Error: Number entered for %s was less than zero.\n", input)
-	} // else no errors
+	return rows
 }
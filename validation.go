@@ -0,0 +1,408 @@
+/*Description: This file checks the numbers the user enters on the forms and
+in the JSON API. Every problem is recorded under the name of its field so
+the page can show the message next to the input, and the API can return
+all of them at once.*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"webtest/solar"
+)
+
+//Largest house or roof size accepted (square feet). Anything higher is not realistic.
+const MaxSize = 100000
+
+//FormErrors maps a field name (the JSON name, such as "roofSize") to the
+//error message for that field.
+type FormErrors map[string]string
+
+//Ways to get the data for a location: use the closest city, or blend the
+//closest cities together.
+const (
+	ModeNearest     = "nearest"
+	ModeInterpolate = "interpolate"
+)
+
+//Largest number of cities that can be blended together.
+const MaxNeighbors = 10
+
+//Largest number of years that can be forecast.
+const MaxYears = 40
+
+//Largest file that can be uploaded (bytes).
+const MaxUpload = 32 << 20
+
+//These are the inputs of one solar estimate after they were checked.
+type EstimateInput struct {
+	Latitude  float64
+	Longitude float64
+	HouseSize float64
+	RoofSize  float64
+	Mode      string //ModeNearest or ModeInterpolate
+	Neighbors int    //Number of cities to blend in ModeInterpolate
+
+	Roof    solar.Orientation   //Pitch and direction of the roof, flat if not given
+	Losses  solar.Losses        //System losses, the defaults unless the API request changes them
+	Panel   string              //Name of the panel for the headline numbers, empty for the first panel
+	Years   int                 //Number of years to forecast
+	Finance solar.FinanceInputs //Electricity price and the other money values of the financial analysis
+	Tariff  string              //ID of the tariff, empty for the closest city's utility
+
+	InstallDate time.Time //Day the panels would be installed, for the incentives that start or end
+
+	Usage *solar.Usage //Usage read from the home's meter or bills, nil to use the city average
+}
+
+//These are the inputs of one heat map after they were checked.
+type HeatMapInput struct {
+	HouseSize float64
+	RoofSize  float64
+	Losses    solar.Losses //System losses, the defaults unless the API request changes them
+	Panel     string       //Name of the panel, empty for the first panel
+}
+
+//These are the inputs of one hourly simulation after they were checked.
+type SimulateInput struct {
+	Latitude  float64
+	Longitude float64
+	RoofSize  float64
+	Roof      solar.Orientation //Pitch and direction of the roof, flat if not given
+	Losses    solar.Losses      //System losses, the defaults unless the request changes them
+	Panel     string            //Name of the panel, empty for the first panel
+}
+
+//Parses a number entered in a form. An empty or invalid number, NaN or an
+//infinity included, is recorded as an error.
+func (errs FormErrors) Number(field, label, value string) float64 {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		errs[field] = fmt.Sprintf("Please enter the %s.", label)
+		return 0
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+		errs[field] = fmt.Sprintf("The %s must be a number.", label)
+		return 0
+	}
+	return number
+}
+
+//Reads a number from a JSON request. A missing number is recorded as an error.
+func (errs FormErrors) Required(field, label string, value *float64) float64 {
+	if value == nil {
+		errs[field] = fmt.Sprintf("The %s is required.", label)
+		return 0
+	}
+	return *value
+}
+
+//Records an error if the value is outside of min and max or not a finite
+//number. Fields that already have an error are left alone.
+func (errs FormErrors) Range(field, label string, value, min, max float64) {
+	if _, ok := errs[field]; ok {
+		return
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) || value < min || value > max {
+		errs[field] = fmt.Sprintf("The %s must be between %g and %g.", label, min, max)
+	}
+}
+
+//Records an error if a house or roof size is not positive, is too big or
+//is not a finite number.
+func (errs FormErrors) Size(field, label string, value float64) {
+	if _, ok := errs[field]; ok {
+		return
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) || value <= 0 || value > MaxSize {
+		errs[field] = fmt.Sprintf("The %s must be greater than 0 and at most %d square feet.", label, MaxSize)
+	}
+}
+
+//Records an error for every system loss that is not a percentage, and for
+//an inverter efficiency that is not realistic.
+func (errs FormErrors) Losses(losses solar.Losses) {
+	items := losses.Items()
+	for _, item := range items[:len(items)-1] { //the last one is the inverter
+		errs.Range("losses."+item.Key, strings.ToLower(item.Name)+" loss", item.Percent, 0, 99)
+	}
+	errs.Range("losses.inverterEfficiency", "inverter efficiency", losses.InverterEfficiency, 50, 100)
+}
+
+//Reads the system losses of a form, whose inputs are named like their JSON
+//keys in lower case. The losses left empty keep their PVWatts defaults.
+func (errs FormErrors) FormLosses(r *http.Request) solar.Losses {
+	losses := solar.DefaultLosses()
+	fields := []struct {
+		key   string
+		label string
+		value *float64
+	}{
+		{"soiling", "soiling loss", &losses.Soiling},
+		{"shading", "shading loss", &losses.Shading},
+		{"snow", "snow loss", &losses.Snow},
+		{"mismatch", "mismatch loss", &losses.Mismatch},
+		{"wiring", "wiring loss", &losses.Wiring},
+		{"connections", "connections loss", &losses.Connections},
+		{"lid", "light induced degradation loss", &losses.LID},
+		{"nameplate", "nameplate rating loss", &losses.Nameplate},
+		{"availability", "availability loss", &losses.Availability},
+		{"inverterEfficiency", "inverter efficiency", &losses.InverterEfficiency},
+	}
+	for _, field := range fields {
+		*field.value = errs.Number("losses."+field.key, field.label, Default(r.Form.Get(strings.ToLower(field.key)), fmt.Sprint(*field.value)))
+	}
+	return losses
+}
+
+//Finds the panel the user chose. An unknown name is recorded as an error
+//under "panel".
+func (errs FormErrors) Panel(data *solar.Dataset, name string) solar.Panel {
+	panel, ok := data.Panel(name)
+	if !ok {
+		errs["panel"] = fmt.Sprintf("We don't have a panel named %q.", name)
+	}
+	return panel
+}
+
+//Records an error for every money value of a financial analysis that is
+//not realistic.
+func (errs FormErrors) Finance(in solar.FinanceInputs) {
+	errs.Range("finance.price", "electricity price", in.Price, 0, 10)
+	errs.Range("finance.escalation", "electricity price escalation", in.Escalation, -20, 20)
+	errs.Range("finance.discount", "discount rate", in.Discount, 0, 50)
+	errs.Range("finance.omCost", "upkeep cost", in.OMCost, 0, 1000)
+	errs.Range("finance.loanRate", "loan interest rate", in.LoanRate, 0, 30)
+	errs.Range("finance.loanYears", "loan term", float64(in.LoanYears), 1, MaxYears)
+	errs.Range("finance.leasePayment", "lease payment", in.LeasePayment, 0, 10000)
+	errs.Range("finance.leaseEscalator", "lease escalator", in.LeaseEscalator, 0, 10)
+	errs.Range("finance.leaseYears", "lease term", float64(in.LeaseYears), 1, MaxYears)
+	errs.Range("finance.ppaPrice", "PPA price", in.PPAPrice, 0, 10)
+	errs.Range("finance.ppaEscalator", "PPA escalator", in.PPAEscalator, 0, 10)
+	errs.Range("finance.ppaYears", "PPA term", float64(in.PPAYears), 1, MaxYears)
+}
+
+//Parses a date written as YYYY-MM-DD. An empty date is today, and an
+//invalid one is recorded as an error.
+func (errs FormErrors) Date(field, label, value string) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Now()
+	}
+	date, err := time.Parse(solar.DateLayout, value)
+	if err != nil {
+		errs[field] = fmt.Sprintf("The %s must be written as YYYY-MM-DD.", label)
+	}
+	return date
+}
+
+//Gives value, or def when the user left it empty.
+func Default(value, def string) string {
+	if strings.TrimSpace(value) == "" {
+		return def
+	}
+	return strings.TrimSpace(value)
+}
+
+//Checks that the coordinates and sizes are within range and the estimate
+//method is known.
+func (in EstimateInput) Check(errs FormErrors) {
+	errs.Range("latitude", "latitude", in.Latitude, -90, 90)
+	errs.Range("longitude", "longitude", in.Longitude, -180, 180)
+	errs.Size("houseSize", "house size", in.HouseSize)
+	errs.Size("roofSize", "roof size", in.RoofSize)
+	if in.Mode != ModeNearest && in.Mode != ModeInterpolate {
+		errs["mode"] = fmt.Sprintf("The mode must be %q or %q.", ModeNearest, ModeInterpolate)
+	}
+	errs.Range("neighbors", "number of cities", float64(in.Neighbors), 1, MaxNeighbors)
+	errs.Range("tilt", "roof pitch", in.Roof.Tilt, 0, 90)
+	errs.Range("azimuth", "roof direction", in.Roof.Azimuth, 0, 360)
+	errs.Losses(in.Losses)
+	errs.Range("years", "number of years", float64(in.Years), 1, MaxYears)
+	errs.Finance(in.Finance)
+}
+
+//Checks that the sizes and losses are within range.
+func (in HeatMapInput) Check(errs FormErrors) {
+	errs.Size("houseSize", "house size", in.HouseSize)
+	errs.Size("roofSize", "roof size", in.RoofSize)
+	errs.Losses(in.Losses)
+}
+
+//Checks that the coordinates, roof size and roof angles are within range.
+func (in SimulateInput) Check(errs FormErrors) {
+	errs.Range("latitude", "latitude", in.Latitude, -90, 90)
+	errs.Range("longitude", "longitude", in.Longitude, -180, 180)
+	errs.Size("roofSize", "roof size", in.RoofSize)
+	errs.Range("tilt", "roof pitch", in.Roof.Tilt, 0, 90)
+	errs.Range("azimuth", "roof direction", in.Roof.Azimuth, 0, 360)
+	errs.Losses(in.Losses)
+}
+
+//These are the files of the home's usage a form can upload.
+var Uploads = []struct {
+	Name  string                               //Name of the file input
+	Field string                               //Name of the field of its errors
+	Label string                               //Name of the file in the messages
+	Read  func(io.Reader) (solar.Usage, error) //Reads the file
+}{
+	{"greenbutton", "greenButton", "Green Button file", solar.ReadGreenButton},
+	{"bills", "bills", "bills file", solar.ReadUtilityBills},
+}
+
+//Reads the Green Button file or the bills uploaded with a form, or gives
+//nil when neither was uploaded. A file that can't be read, or uploading
+//both, is recorded as an error.
+func (errs FormErrors) Upload(r *http.Request) *solar.Usage {
+	var usage *solar.Usage
+	for _, upload := range Uploads {
+		file, _, err := r.FormFile(upload.Name)
+		if err == http.ErrMissingFile || err == http.ErrNotMultipart {
+			continue
+		} else if err != nil {
+			errs[upload.Field] = fmt.Sprintf("The %s could not be uploaded: %v.", upload.Label, err)
+			continue
+		}
+		read, err := upload.Read(file)
+		file.Close()
+		switch {
+		case err != nil:
+			errs[upload.Field] = fmt.Sprintf("The %s can't be read: %v.", upload.Label, err)
+		case usage != nil:
+			errs[upload.Field] = "Upload either a Green Button file or your bills, not both."
+		default:
+			usage = &read
+		}
+	}
+	return usage
+}
+
+//Reads and checks the inputs of the /selected form, which may upload a
+//Green Button file or a bills file.
+func ParseEstimateForm(r *http.Request) (EstimateInput, FormErrors) {
+	r.ParseMultipartForm(MaxUpload) //Parse the page for the variables needed; a form without a file is parsed too
+	errs := make(FormErrors)
+	var in EstimateInput
+	in.Latitude = errs.Number("latitude", "latitude", r.Form.Get("coordinaten"))
+	in.Longitude = errs.Number("longitude", "longitude", r.Form.Get("coordinatew"))
+	in.HouseSize = errs.Number("houseSize", "house size", r.Form.Get("housesize"))
+	in.RoofSize = errs.Number("roofSize", "roof size", r.Form.Get("roofsize"))
+	in.Mode = Default(r.Form.Get("mode"), ModeNearest)
+	in.Neighbors = int(errs.Number("neighbors", "number of cities", Default(r.Form.Get("neighbors"), "4")))
+	in.Roof.Tilt = errs.Number("tilt", "roof pitch", Default(r.Form.Get("tilt"), "0"))
+	in.Roof.Azimuth = errs.Number("azimuth", "roof direction", Default(r.Form.Get("azimuth"), "180"))
+	in.Losses = errs.FormLosses(r)
+	in.Panel = strings.TrimSpace(r.Form.Get("panel"))
+	in.Years = int(errs.Number("years", "number of years", Default(r.Form.Get("years"), strconv.Itoa(solar.DefaultForecastYears))))
+	in.Finance = solar.DefaultFinance()
+	in.Finance.Price = errs.Number("finance.price", "electricity price", Default(r.Form.Get("price"), "0"))
+	in.Finance.LoanRate = errs.Number("finance.loanRate", "loan interest rate", Default(r.Form.Get("loanrate"), fmt.Sprint(in.Finance.LoanRate)))
+	in.Finance.LoanYears = int(errs.Number("finance.loanYears", "loan term", Default(r.Form.Get("loanyears"), strconv.Itoa(in.Finance.LoanYears))))
+	in.Finance.LeasePayment = errs.Number("finance.leasePayment", "lease payment", Default(r.Form.Get("leasepayment"), "0"))
+	in.Finance.PPAPrice = errs.Number("finance.ppaPrice", "PPA price", Default(r.Form.Get("ppaprice"), "0"))
+	in.Tariff = strings.TrimSpace(r.Form.Get("tariff"))
+	in.InstallDate = time.Now()
+	in.Usage = errs.Upload(r)
+	in.Check(errs)
+	return in, errs
+}
+
+//Reads and checks the inputs of the /displayheatmap form.
+func ParseHeatMapForm(r *http.Request) (HeatMapInput, FormErrors) {
+	r.ParseForm() //Parse the page for the variables needed
+	errs := make(FormErrors)
+	var in HeatMapInput
+	in.HouseSize = errs.Number("houseSize", "house size", r.Form.Get("housesizeinput"))
+	in.RoofSize = errs.Number("roofSize", "roof size", r.Form.Get("roofsize"))
+	in.Losses = solar.DefaultLosses()
+	in.Panel = strings.TrimSpace(r.Form.Get("panel"))
+	in.Check(errs)
+	return in, errs
+}
+
+//Reads and checks the inputs of a POST to /api/v1/estimate.
+func (req EstimateRequest) Input() (EstimateInput, FormErrors) {
+	errs := make(FormErrors)
+	var in EstimateInput
+	in.Latitude = errs.Required("latitude", "latitude", req.Latitude)
+	in.Longitude = errs.Required("longitude", "longitude", req.Longitude)
+	in.HouseSize = errs.Required("houseSize", "house size", req.HouseSize)
+	in.RoofSize = errs.Required("roofSize", "roof size", req.RoofSize)
+	in.Mode = Default(req.Mode, ModeNearest)
+	in.Neighbors = 4
+	if req.Neighbors != nil {
+		in.Neighbors = *req.Neighbors
+	}
+	in.Roof = RoofInput(req.Tilt, req.Azimuth)
+	in.Losses = req.Losses
+	in.Panel = strings.TrimSpace(req.Panel)
+	in.Years = solar.DefaultForecastYears
+	if req.Years != nil {
+		in.Years = *req.Years
+	}
+	in.Finance = req.Finance
+	in.Tariff = strings.TrimSpace(req.Tariff)
+	in.InstallDate = errs.Date("installDate", "installation date", req.InstallDate)
+	if req.Usage != nil && len(req.Usage.Bills) > 0 {
+		usage, err := solar.NewBillUsage(req.Usage.Bills)
+		if err != nil {
+			errs["usage.bills"] = fmt.Sprintf("The bills are invalid: %v.", err)
+		}
+		in.Usage = &usage
+	} else if req.Usage != nil {
+		usage, err := solar.NewUsage(req.Usage.Hourly)
+		if err != nil {
+			errs["usage.hourly"] = fmt.Sprintf("The hourly usage is invalid: %v.", err)
+		}
+		usage.From, usage.To = req.Usage.From, req.Usage.To
+		in.Usage = &usage
+	}
+	in.Check(errs)
+	return in, errs
+}
+
+//Reads and checks the inputs of a POST to /api/v1/heatmap.
+func (req HeatMapRequest) Input() (HeatMapInput, FormErrors) {
+	errs := make(FormErrors)
+	var in HeatMapInput
+	in.HouseSize = errs.Required("houseSize", "house size", req.HouseSize)
+	in.RoofSize = errs.Required("roofSize", "roof size", req.RoofSize)
+	in.Losses = req.Losses
+	in.Panel = strings.TrimSpace(req.Panel)
+	in.Check(errs)
+	return in, errs
+}
+
+//Reads and checks the inputs of a POST to /api/v1/simulate.
+func (req SimulateRequest) Input() (SimulateInput, FormErrors) {
+	errs := make(FormErrors)
+	var in SimulateInput
+	in.Latitude = errs.Required("latitude", "latitude", req.Latitude)
+	in.Longitude = errs.Required("longitude", "longitude", req.Longitude)
+	in.RoofSize = errs.Required("roofSize", "roof size", req.RoofSize)
+	in.Roof = RoofInput(req.Tilt, req.Azimuth)
+	in.Losses = req.Losses
+	in.Panel = strings.TrimSpace(req.Panel)
+	in.Check(errs)
+	return in, errs
+}
+
+//Gives the roof orientation of a JSON request: flat and facing south
+//unless the request says otherwise.
+func RoofInput(tilt, azimuth *float64) solar.Orientation {
+	roof := solar.Orientation{Azimuth: 180}
+	if tilt != nil {
+		roof.Tilt = *tilt
+	}
+	if azimuth != nil {
+		roof.Azimuth = *azimuth
+	}
+	return roof
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"webtest/solar"
+)
+
+func TestFormErrors(t *testing.T) {
+	errs := make(FormErrors)
+	latitude := 35.5
+	if errs.Required("latitude", "latitude", &latitude) != 35.5 || errs.Required("roofSize", "roof size", nil) != 0 {
+		t.Error("Required gave the wrong value")
+	}
+	if errs.Number("houseSize", "house size", " 2000 ") != 2000 {
+		t.Error("Number didn't trim the spaces")
+	}
+	errs.Number("tilt", "roof pitch", "")
+	errs.Number("azimuth", "roof direction", "south")
+	errs.Number("years", "number of years", "NaN")
+	errs.Number("neighbors", "number of cities", "Inf")
+
+	//The bounds are allowed.
+	errs.Range("latitude", "latitude", 90, -90, 90)
+	errs.Range("longitude", "longitude", -180, -180, 180)
+	errs.Size("houseSize", "house size", MaxSize)
+	//The roof size is missing: the message that it is required stays.
+	errs.Size("roofSize", "roof size", 0)
+	errs.Range("years", "number of years", 500, 1, MaxYears)
+	errs.Range("finance.price", "electricity price", math.NaN(), 0, 10)
+	errs.Range("finance.loanRate", "loan interest rate", 30.01, 0, 30)
+	errs.Size("size.zero", "size", 0)
+	errs.Size("size.big", "size", MaxSize+0.5)
+	errs.Size("size.infinite", "size", math.Inf(1))
+
+	size := "must be greater than 0 and at most 100000 square feet."
+	want := FormErrors{
+		"roofSize":         "The roof size is required.",
+		"tilt":             "Please enter the roof pitch.",
+		"azimuth":          "The roof direction must be a number.",
+		"years":            "The number of years must be a number.",
+		"neighbors":        "The number of cities must be a number.",
+		"finance.price":    "The electricity price must be between 0 and 10.",
+		"finance.loanRate": "The loan interest rate must be between 0 and 30.",
+		"size.zero":        "The size " + size,
+		"size.big":         "The size " + size,
+		"size.infinite":    "The size " + size,
+	}
+	if len(errs) != len(want) {
+		t.Errorf("got %d errors, want %d: %v", len(errs), len(want), errs)
+	}
+	for field, message := range want {
+		if errs[field] != message {
+			t.Errorf("%s: got %q, want %q", field, errs[field], message)
+		}
+	}
+}
+
+//Makes a POST of the /selected form with the values and the files, keyed
+//by input name.
+func estimateForm(t *testing.T, values url.Values, files map[string]string) *http.Request {
+	t.Helper()
+	if files == nil {
+		r := httptest.NewRequest(http.MethodPost, "/selected", strings.NewReader(values.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return r
+	}
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name := range values {
+		form.WriteField(name, values.Get(name))
+	}
+	for name, content := range files {
+		file, err := form.CreateFormFile(name, name+".txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Write([]byte(content))
+	}
+	form.Close()
+	r := httptest.NewRequest(http.MethodPost, "/selected", &body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	return r
+}
+
+//The inputs of a home in Albuquerque.
+func albuquerqueForm() url.Values {
+	return url.Values{"coordinaten": {"35.0853"}, "coordinatew": {"-106.6056"}, "housesize": {"2000"}, "roofsize": {"1500"}}
+}
+
+func TestParseEstimateForm(t *testing.T) {
+	in, errs := ParseEstimateForm(estimateForm(t, albuquerqueForm(), nil))
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	//What the user left out keeps its default.
+	if in.Latitude != 35.0853 || in.Longitude != -106.6056 || in.HouseSize != 2000 || in.RoofSize != 1500 || in.Mode != ModeNearest ||
+		in.Neighbors != 4 || in.Roof != (solar.Orientation{Azimuth: 180}) || in.Losses != solar.DefaultLosses() ||
+		in.Years != solar.DefaultForecastYears || in.Finance.LoanYears != solar.DefaultFinance().LoanYears || in.Usage != nil {
+		t.Errorf("got %+v", in)
+	}
+
+	values := url.Values{"coordinaten": {"north"}, "housesize": {"0"}, "roofsize": {"200000"}, "mode": {"closest"}, "tilt": {"91"}, "years": {"0"}, "price": {"ten"}}
+	_, errs = ParseEstimateForm(estimateForm(t, values, nil))
+	want := FormErrors{
+		"latitude":      "The latitude must be a number.",
+		"longitude":     "Please enter the longitude.",
+		"houseSize":     "The house size must be greater than 0 and at most 100000 square feet.",
+		"roofSize":      "The roof size must be greater than 0 and at most 100000 square feet.",
+		"mode":          `The mode must be "nearest" or "interpolate".`,
+		"tilt":          "The roof pitch must be between 0 and 90.",
+		"years":         "The number of years must be between 1 and 40.",
+		"finance.price": "The electricity price must be a number.",
+	}
+	if len(errs) != len(want) {
+		t.Errorf("got %d errors, want %d: %v", len(errs), len(want), errs)
+	}
+	for field, message := range want {
+		if errs[field] != message {
+			t.Errorf("%s: got %q, want %q", field, errs[field], message)
+		}
+	}
+}
+
+func TestFormLosses(t *testing.T) {
+	values := albuquerqueForm()
+	values.Set("soiling", "5")
+	values.Set("lid", " 0 ")
+	values.Set("inverterefficiency", "97.5")
+	in, errs := ParseEstimateForm(estimateForm(t, values, nil))
+	want := solar.DefaultLosses()
+	want.Soiling, want.LID, want.InverterEfficiency = 5, 0, 97.5
+	if len(errs) != 0 || in.Losses != want {
+		t.Errorf("got %+v and %v, want %+v", in.Losses, errs, want)
+	}
+
+	values.Set("snow", "a lot")
+	values.Set("shading", "100")
+	values.Set("nameplate", "-1")
+	values.Set("inverterefficiency", "40")
+	_, errs = ParseEstimateForm(estimateForm(t, values, nil))
+	wantErrs := FormErrors{
+		"losses.snow":               "The snow loss must be a number.",
+		"losses.shading":            "The shading loss must be between 0 and 99.",
+		"losses.nameplate":          "The nameplate rating loss must be between 0 and 99.",
+		"losses.inverterEfficiency": "The inverter efficiency must be between 50 and 100.",
+	}
+	if len(errs) != len(wantErrs) {
+		t.Errorf("got %d errors, want %d: %v", len(errs), len(wantErrs), errs)
+	}
+	for field, message := range wantErrs {
+		if errs[field] != message {
+			t.Errorf("%s: got %q, want %q", field, errs[field], message)
+		}
+	}
+}
+
+func TestUpload(t *testing.T) {
+	bills := "start,end,kwh,cost\n2023-01-01,2023-01-31,744,100\n"
+	tests := []struct {
+		name  string
+		files map[string]string
+		usage string //the source of the usage read, empty for none
+		errs  FormErrors
+	}{
+		{"no file", map[string]string{}, "", FormErrors{}},
+		{"bills", map[string]string{"bills": bills}, solar.UtilityBills, FormErrors{}},
+		{"bad bills", map[string]string{"bills": "2023-01-01,2023-01-31,lots,100\n"}, "",
+			FormErrors{"bills": `The bills file can't be read: bills:1: column 3: bad value "lots": not a number.`}},
+		{"not a Green Button file", map[string]string{"greenbutton": bills}, "",
+			FormErrors{"greenButton": "The Green Button file can't be read: not a Green Button file: EOF."}},
+		{"both", map[string]string{"greenbutton": `<feed><entry><content><IntervalBlock><IntervalReading><timePeriod><duration>3600</duration><start>1672531200</start></timePeriod><value>1000</value></IntervalReading></IntervalBlock></content></entry></feed>`,
+			"bills": bills}, solar.MeterReadings,
+			FormErrors{"bills": "Upload either a Green Button file or your bills, not both."}},
+	}
+	for _, test := range tests {
+		r := estimateForm(t, albuquerqueForm(), test.files)
+		r.ParseMultipartForm(MaxUpload)
+		errs := make(FormErrors)
+		usage := errs.Upload(r)
+		if usage == nil && test.usage != "" || usage != nil && usage.Source != test.usage {
+			t.Errorf("%s: got the usage %+v, want %q", test.name, usage, test.usage)
+		}
+		if len(errs) != len(test.errs) {
+			t.Errorf("%s: got %v, want %v", test.name, errs, test.errs)
+		}
+		for field, message := range test.errs {
+			if errs[field] != message {
+				t.Errorf("%s: got %q under %s, want %q", test.name, errs[field], field, message)
+			}
+		}
+	}
+
+	//A file over MaxUpload isn't read.
+	server, _ := testServer(t)
+	r := estimateForm(t, albuquerqueForm(), map[string]string{"bills": bills + strings.Repeat("\n", MaxUpload)})
+	w := httptest.NewRecorder()
+	server.UserSelected(w, r)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "The bills file could not be uploaded") {
+		t.Errorf("a file over MaxUpload: got %d", w.Code)
+	}
+}
+
+func TestUserSelectedKeepsValues(t *testing.T) {
+	server, _ := testServer(t)
+	values := albuquerqueForm()
+	values.Set("roofsize", "-5")
+	values.Set("tilt", "30")
+	values.Set("panel", "CanadianSolar")
+	values.Set("mode", "interpolate")
+	values.Set("shading", "7")
+	w := httptest.NewRecorder()
+	server.UserSelected(w, estimateForm(t, values, nil))
+	page := w.Body.String()
+	if w.Code != http.StatusBadRequest || !strings.Contains(page, "The roof size must be greater than 0") {
+		t.Fatalf("a negative roof: got %d", w.Code)
+	}
+	for _, kept := range []string{`name="coordinaten" id = "northinput" onkeyup= "checkInput();" value = "35.0853"`,
+		`value = "2000"`, `value = "-5"`, `name="tilt" size = "5" value = "30"`,
+		`<option value = "CanadianSolar" selected>`, `<option value = "interpolate" selected>`, `name="shading" size = "4" value = "7"`} {
+		if !strings.Contains(page, kept) {
+			t.Errorf("the page lost %s", kept)
+		}
+	}
+}